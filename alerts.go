@@ -0,0 +1,106 @@
+package lucrum
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// alert fires once when a symbol's price crosses value, then is removed.
+// Alerts are saved in the config as strings like "AAPL > 200".
+type alert struct {
+	symbol string
+	op     string
+	value  float64
+}
+
+func parseAlert(args []string) (alert, error) {
+	a := alert{}
+	// Spaces are optional, e.g. "AAPL >200" or "AAPL>200"
+	args = splitAlert(strings.Join(args, ""))
+	if len(args) != 3 || args[0] == "" {
+		return a, errors.New("Usage: SYMBOL > PRICE")
+	}
+	a.symbol = strings.ToUpper(args[0])
+	a.op = args[1]
+	v, err := strconv.ParseFloat(strings.TrimPrefix(args[2], "$"), 64)
+	if err != nil {
+		return a, errors.New("Invalid price: " + args[2])
+	}
+	a.value = v
+	return a, nil
+}
+
+// splitAlert separates "AAPL>200" into its parts.
+func splitAlert(s string) []string {
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if i := strings.Index(s, op); i != -1 {
+			return []string{s[:i], op, s[i+len(op):]}
+		}
+	}
+	return []string{s}
+}
+
+func (a alert) String() string {
+	return fmt.Sprintf("%s %s %s", a.symbol, a.op, strconv.FormatFloat(a.value, 'f', -1, 64))
+}
+
+func (a alert) triggered(price float64) bool {
+	switch a.op {
+	case ">":
+		return price > a.value
+	case "<":
+		return price < a.value
+	case ">=":
+		return price >= a.value
+	case "<=":
+		return price <= a.value
+	}
+	return false
+}
+
+func (luc *Lucrum) loadAlerts() error {
+	luc.alerts = nil
+	for _, s := range luc.conf.Alerts {
+		a, err := parseAlert(strings.Fields(s))
+		if err != nil {
+			return errors.New("Invalid alert " + s + ": " + err.Error())
+		}
+		luc.alerts = append(luc.alerts, a)
+	}
+	return nil
+}
+
+func (luc *Lucrum) saveAlerts() error {
+	luc.conf.Alerts = nil
+	for _, a := range luc.alerts {
+		luc.conf.Alerts = append(luc.conf.Alerts, a.String())
+	}
+	return luc.saveConfig()
+}
+
+// checkAlerts fires the alerts whose condition is met by the latest quotes.
+func (luc *Lucrum) checkAlerts() {
+	var fired []string
+	var remaining []alert
+	for _, a := range luc.alerts {
+		s, ok := luc.stock(a.symbol)
+		if ok && s.RegularMarketPrice != 0 && a.triggered(s.RegularMarketPrice) {
+			fired = append(fired, fmt.Sprintf("%s (now %s)", a, s.FormattedRegularMarketPrice))
+			continue
+		}
+		remaining = append(remaining, a)
+	}
+	if len(fired) == 0 {
+		return
+	}
+
+	luc.alerts = remaining
+	if err := luc.saveAlerts(); err != nil {
+		luc.setStatus("Alert: %s (could not save the config: %s)", strings.Join(fired, "; "), err)
+	} else {
+		luc.setStatus("Alert: %s", strings.Join(fired, "; "))
+	}
+	luc.cviewApp.RingBell()
+}
@@ -0,0 +1,64 @@
+package lucrum
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestParseAlert(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+		err  bool
+	}{
+		{[]string{"aapl", ">", "200"}, "AAPL > 200", false},
+		{[]string{"AAPL", ">200"}, "AAPL > 200", false},
+		{[]string{"AAPL>", "200"}, "AAPL > 200", false},
+		{[]string{"BTC-USD<=$60000.5"}, "BTC-USD <= 60000.5", false},
+		{[]string{"AAPL", "=", "200"}, "", true},
+		{[]string{"AAPL", ">", "lots"}, "", true},
+		{[]string{">", "200"}, "", true},
+		{[]string{"AAPL"}, "", true},
+	}
+	for _, test := range tests {
+		a, err := parseAlert(test.args)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: got %s, want an error", test.args, a)
+			}
+			continue
+		}
+		if err != nil || a.String() != test.want {
+			t.Errorf("%q: got %s, %v, want %s", test.args, a, err, test.want)
+		}
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87))
+	h := newHarness(t, `Symbols = ["AAPL"]`, quotes)
+	h.tick(time.Second)
+
+	h.press(":alert TSLA >200")
+	h.key(tcell.KeyEnter, 0)
+	if !strings.Contains(h.text(), "TSLA is not in the watchlist") || len(h.luc.alerts) != 0 {
+		t.Errorf("alert on a symbol outside the watchlist accepted:\n%s", h.text())
+	}
+	h.press(":alert AAPL >180")
+	h.key(tcell.KeyEnter, 0)
+	if !strings.Contains(h.conf(), `Alerts = ["AAPL > 180"]`) {
+		t.Errorf("alert not saved:\n%s", h.conf())
+	}
+
+	// A config that can't be saved is reported rather than crashing
+	h.do(func() { h.luc.configPath = filepath.Join(h.dir, "missing", "conf") })
+	quotes.set(quote("AAPL", 181))
+	h.tick(5 * time.Second)
+	if !strings.Contains(h.text(), "Alert: AAPL > 180 (now $181.00) (could not save the config") {
+		t.Errorf("alert not reported:\n%s", h.text())
+	}
+}
@@ -0,0 +1,60 @@
+package lucrum
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/coingecko"
+	"github.com/anorb/lucrum/pkg/fetch"
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+func (luc *Lucrum) applyRateLimits() {
+	if limit := luc.conf.RateLimits["yahoo"]; limit > 0 {
+		yahoofinance.SetRateLimit(limit, 10)
+	}
+	if limit := luc.conf.RateLimits["coingecko"]; limit > 0 {
+		coingecko.SetRateLimit(limit, 5)
+	}
+}
+
+func budgetText() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-26s %10s %6s %9s %8s %9s %5s %7s\n", "Host", "Limit/min", "Burst", "Available", "Requests", "Throttled", "429s", "Retries")
+	for _, budget := range fetch.Budgets() {
+		limit := "none"
+		if budget.Rate > 0 {
+			limit = fmt.Sprintf("%.0f", budget.Rate*60)
+		}
+		fmt.Fprintf(&b, "%-26s %10s %6d %9.1f %8d %9d %5d %7d", budget.Host, limit, budget.Burst, budget.Available,
+			budget.Requests, budget.Throttled, budget.RateLimited, budget.Retries)
+		if wait := time.Until(budget.PausedUntil); wait > 0 {
+			fmt.Fprintf(&b, "  paused for %s", wait.Round(time.Second))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (luc *Lucrum) showBudget() {
+	view := cview.NewTextView().SetText(budgetText())
+	view.SetBorder(true).SetTitle(" Request budget ")
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' {
+			luc.pages.RemovePage("budget")
+			luc.cviewApp.SetFocus(luc.stockTable)
+			return nil
+		}
+		if event.Rune() == 'u' {
+			view.SetText(budgetText())
+			return nil
+		}
+		return event
+	})
+
+	luc.pages.AddPage("budget", view, true, true)
+	luc.cviewApp.SetFocus(view)
+}
@@ -0,0 +1,126 @@
+package lucrum
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/anorb/lucrum/pkg/format"
+	"github.com/anorb/lucrum/pkg/indicators"
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+var defaultOverlays = []string{"SMA(50)", "SMA(200)"}
+
+var overlayColors = []tcell.Color{tcell.ColorYellow, tcell.ColorAqua, tcell.ColorFuchsia, tcell.ColorOrange}
+
+// showChart fetches a year of daily candles in the background and opens
+// the chart once they arrive.
+func (luc *Lucrum) showChart(symbol string) {
+	luc.setStatus("Loading the chart of %s", symbol)
+	go func() {
+		candles, err := luc.fetchCandles(symbol)
+		luc.cviewApp.QueueUpdateDraw(func() {
+			if err != nil {
+				luc.setStatus("Chart of %s failed: %s", symbol, err)
+				return
+			}
+			luc.setStatus("%s", luc.flagSummary())
+			luc.openChart(symbol, candles)
+		})
+	}()
+}
+
+func (luc *Lucrum) openChart(symbol string, candles []indicators.Candle) {
+	names := luc.conf.Overlays
+	if len(names) == 0 {
+		names = defaultOverlays
+	}
+	var overlays []indicators.Spec
+	for _, name := range names {
+		spec, err := indicators.ParseSpec(name)
+		if err != nil || !spec.Overlay() {
+			continue
+		}
+		overlays = append(overlays, spec)
+	}
+
+	chart := cview.NewBox()
+	chart.SetDrawFunc(func(screen tcell.Screen, x, y, width, height int) (int, int, int, int) {
+		drawChart(screen, x, y, width, height, symbol, candles, overlays)
+		return x, y, width, height
+	})
+	chart.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' {
+			luc.pages.RemovePage("chart")
+			luc.cviewApp.SetFocus(luc.stockTable)
+			return nil
+		}
+		return event
+	})
+
+	luc.pages.AddPage("chart", chart, true, true)
+	luc.cviewApp.SetFocus(chart)
+}
+
+func drawChart(screen tcell.Screen, x, y, width, height int, symbol string, candles []indicators.Candle, overlays []indicators.Spec) {
+	if len(candles) == 0 || width <= 0 || height <= 2 {
+		return
+	}
+
+	// One candle per column, most recent on the right
+	start := 0
+	if len(candles) > width {
+		start = len(candles) - width
+	}
+	closes := indicators.Closes(candles)
+	var lines [][]float64
+	var colors []tcell.Color
+	for i, spec := range overlays {
+		for _, series := range spec.Series(candles) {
+			lines = append(lines, series)
+			colors = append(colors, overlayColors[i%len(overlayColors)])
+		}
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, series := range append([][]float64{closes}, lines...) {
+		for _, v := range series[start:] {
+			if math.IsNaN(v) {
+				continue
+			}
+			low = math.Min(low, v)
+			high = math.Max(high, v)
+		}
+	}
+	if high == low {
+		high = low + 1
+	}
+
+	plotHeight := height - 1
+	plot := func(series []float64, ch rune, color tcell.Color) {
+		for i, v := range series[start:] {
+			if math.IsNaN(v) {
+				continue
+			}
+			row := int((high - v) / (high - low) * float64(plotHeight-1))
+			screen.SetContent(x+i, y+1+row, ch, nil, tcell.StyleDefault.Foreground(color))
+		}
+	}
+
+	for i, series := range lines {
+		plot(series, '·', colors[i])
+	}
+	plot(closes, '•', tcell.ColorDefault)
+
+	last := candles[len(candles)-1].Close
+	title := fmt.Sprintf("%s %s  high %s  low %s", symbol, format.Number(last, format.Precision(last, 2)),
+		format.Number(high, format.Precision(high, 2)), format.Number(low, format.Precision(low, 2)))
+	cview.Print(screen, title, x, y, width, cview.AlignLeft, tcell.ColorDefault)
+	legendX := x + len(title) + 2
+	for i, spec := range overlays {
+		label := spec.String()
+		cview.Print(screen, label, legendX, y, width-(legendX-x), cview.AlignLeft, overlayColors[i%len(overlayColors)])
+		legendX += len(label) + 2
+	}
+}
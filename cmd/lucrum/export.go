@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/anorb/lucrum"
+)
+
+const exportUsage = `Usage: lucrum export [flags]
+
+Writes the watchlist with fresh quotes, as the table would show it.
+
+Flags:
+`
+
+// export is "lucrum export", which writes the watchlist without opening
+// the interface, e.g. for a cron job or a chat snapshot.
+func export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "", "csv, json, md or html, by default from the output's extension or csv")
+	output := flags.String("o", "-", "file to write, - for stdout")
+	raw := flags.Bool("raw", false, "unformatted numbers, and whole quotes in JSON")
+	sort := flags.String("sort", "", `column to sort by, e.g. "Change% desc"`)
+	list := flags.String("list", "", "only one type of quote, e.g. crypto or etf")
+	offline := flags.Bool("offline", false, "use the last cached quotes instead of fetching")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), exportUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return errors.New("unexpected arguments")
+	}
+
+	if *format == "" {
+		*format = "csv"
+		if *output != "-" {
+			*format = *output
+		}
+	}
+	f, err := lucrum.FormatOf(*format)
+	if err != nil {
+		return err
+	}
+
+	luc, err := lucrum.New(lucrum.Options{})
+	if err != nil {
+		return err
+	}
+	if !*offline {
+		if err := luc.Fetch(); err != nil {
+			return err
+		}
+	}
+	if *sort != "" {
+		if err := luc.Command("sort " + *sort); err != nil {
+			return err
+		}
+	}
+	if *list != "" {
+		if err := luc.Command("list " + *list); err != nil {
+			return err
+		}
+	}
+
+	if *output == "-" {
+		return luc.Export(os.Stdout, f, *raw)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := luc.Export(file, f, *raw); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
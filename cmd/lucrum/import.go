@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/anorb/lucrum"
+)
+
+const importUsage = `Usage: lucrum import [flags] FILE...
+
+Reads position or activity CSVs downloaded from Fidelity, Schwab, Vanguard
+or Interactive Brokers, or any broker with an [ImportProfiles] mapping in
+the config, into the holdings.
+
+Flags:
+`
+
+// importHoldings is "lucrum import".
+func importHoldings(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	var opts lucrum.ImportOptions
+	flags.StringVar(&opts.Broker, "broker", "", "fidelity, schwab, vanguard, ibkr or an import profile, detected when empty")
+	flags.StringVar(&opts.Account, "account", "", "account of rows without one, the broker's name when empty")
+	flags.BoolVar(&opts.Watch, "watch", true, "add the symbols to the watchlist")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "show the changes without saving them")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), importUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no files given")
+	}
+
+	luc, err := lucrum.New(lucrum.Options{})
+	if err != nil {
+		return err
+	}
+	for _, path := range flags.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", path)
+		err = luc.Import(f, opts, os.Stdout)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -1,10 +1,28 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/anorb/lucrum"
 )
 
+var subcommands = map[string]func(args []string) error{
+	"export": export,
+	"import": importHoldings,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "lucrum %s: %s\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	luc := lucrum.Init()
 
 	go luc.UpdateLoop()
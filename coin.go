@@ -0,0 +1,277 @@
+package lucrum
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/anorb/lucrum/pkg/coingecko"
+	"github.com/anorb/lucrum/pkg/format"
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+// CoinGecko descriptions are HTML, mostly links
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// locales returns the user's preferred CoinGecko language keys, most
+// specific first, e.g. "pt-br", "pt", then "en".
+func locales() []string {
+	var tags []string
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(env)
+		if value == "" || value == "C" || value == "POSIX" {
+			continue
+		}
+		// de_DE.UTF-8@euro -> de-de
+		value = strings.SplitN(value, ".", 2)[0]
+		value = strings.SplitN(value, "@", 2)[0]
+		value = strings.ToLower(strings.Replace(value, "_", "-", 1))
+		tags = append(tags, value, strings.SplitN(value, "-", 2)[0])
+		break
+	}
+	return append(tags, "en")
+}
+
+// localized picks the entry of a CoinGecko language map for the user's
+// locale, falling back to English.
+func localized(m map[string]string) string {
+	for _, tag := range locales() {
+		if text := strings.TrimSpace(m[tag]); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+func plainText(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+}
+
+func nonEmpty(links []string) []string {
+	var out []string
+	for _, l := range links {
+		if strings.TrimSpace(l) != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func (luc *Lucrum) coinText(c coingecko.CoinResponse) string {
+	currency := luc.currency()
+	m := c.MarketData
+	money := func(v float64) string {
+		return format.Money(v, format.Precision(v, 2), currency)
+	}
+
+	var b strings.Builder
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%-18s %s\n", label, value)
+		}
+	}
+	heading := func(title string) {
+		fmt.Fprintf(&b, "\n%s\n", title)
+	}
+
+	name := localized(c.Localization)
+	if name == "" {
+		name = c.Name
+	}
+	line("Name", name+" ("+strings.ToUpper(c.Symbol)+")")
+	if c.MarketCapRank > 0 {
+		line("Market cap rank", fmt.Sprintf("#%d", c.MarketCapRank))
+	}
+	line("Categories", strings.Join(nonEmpty(c.Categories), ", "))
+	line("Platform", c.AssetPlatformID)
+	line("Hashing algorithm", c.HashingAlgorithm)
+	line("Genesis", c.GenesisDate)
+	line("Origin", c.CountryOrigin)
+	if c.PublicNotice != "" {
+		line("Notice", plainText(c.PublicNotice))
+	}
+
+	heading("Market")
+	line("Price", money(m.CurrentPrice[currency]))
+	line("24h range", money(m.Low24[currency])+" - "+money(m.High24[currency]))
+	line("Market cap", format.AbbreviateMoney(m.MarketCap[currency], currency))
+	if v := m.FullyDilutedValuation[currency]; v > 0 {
+		line("Fully diluted", format.AbbreviateMoney(v, currency))
+	}
+	line("Volume", format.AbbreviateMoney(m.TotalVolume[currency], currency))
+	for _, change := range []struct {
+		label  string
+		values coingecko.Currencies
+	}{
+		{"Change 1h", m.PriceChangePercentage1hInCurrency},
+		{"Change 24h", m.PriceChangePercentage24hInCurrency},
+		{"Change 7d", m.PriceChangePercentage7dInCurrency},
+		{"Change 30d", m.PriceChangePercentage30dInCurrency},
+		{"Change 1y", m.PriceChangePercentage1yInCurrency},
+	} {
+		if v, ok := change.values[currency]; ok {
+			line(change.label, luc.glyph(v)+format.Percentage(v))
+		}
+	}
+	if d, ok := m.AthDate[currency]; ok {
+		line("All time high", fmt.Sprintf("%s on %s (%s)", money(m.Ath[currency]), d.Format("2006-01-02"), format.Percentage(m.AthChangePercentage[currency])))
+	}
+	if d, ok := m.AtlDate[currency]; ok {
+		line("All time low", fmt.Sprintf("%s on %s (%s)", money(m.Atl[currency]), d.Format("2006-01-02"), format.Percentage(m.AtlChangePercentage[currency])))
+	}
+	if m.ROI.Currency != "" {
+		line("ROI", fmt.Sprintf("%.2fx (%s in %s)", m.ROI.Times, format.Percentage(m.ROI.Percentage), strings.ToUpper(m.ROI.Currency)))
+	}
+
+	heading("Supply")
+	line("Circulating", supply(m.CirculatingSupply))
+	line("Total", supply(m.TotalSupply))
+	line("Max", supply(m.MaxSupply))
+
+	heading("Sentiment and scores")
+	if c.SentimentVotesUpPercentage+c.SentimentVotesDownPercentage > 0 {
+		line("Sentiment", fmt.Sprintf("%.0f%% up, %.0f%% down", c.SentimentVotesUpPercentage, c.SentimentVotesDownPercentage))
+	}
+	if c.CoingeckoRank > 0 {
+		line("CoinGecko", fmt.Sprintf("%.1f (rank #%d)", c.CoingeckoScore, c.CoingeckoRank))
+	}
+	line("Developer", fmt.Sprintf("%.1f", c.DeveloperScore))
+	line("Community", fmt.Sprintf("%.1f", c.CommunityScore))
+	line("Liquidity", fmt.Sprintf("%.1f", c.LiquidityScore))
+	line("Public interest", fmt.Sprintf("%.1f", c.PublicInterestScore))
+
+	heading("Links")
+	for _, l := range nonEmpty(c.Links.Homepage) {
+		line("Homepage", l)
+	}
+	for _, l := range nonEmpty(c.Links.BlockchainSite) {
+		line("Explorer", l)
+	}
+	for _, l := range nonEmpty(c.Links.OfficialForumURL) {
+		line("Forum", l)
+	}
+	for _, l := range nonEmpty(c.Links.ReposURL.Github) {
+		line("GitHub", l)
+	}
+	for _, l := range nonEmpty(c.Links.ReposURL.Bitbucket) {
+		line("Bitbucket", l)
+	}
+	line("Reddit", c.Links.SubredditURL)
+	if c.Links.TwitterScreenName != "" {
+		line("Twitter", "https://twitter.com/"+c.Links.TwitterScreenName)
+	}
+
+	if description := localized(c.Description); description != "" {
+		heading("About")
+		b.WriteString(plainText(description))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// showCoin fetches a coin from CoinGecko and shows everything about it.
+// Closing it returns focus to whatever opened it.
+func (luc *Lucrum) showCoin(id string) {
+	previous := luc.cviewApp.GetFocus()
+	view := cview.NewTextView().SetWrap(true).SetWordWrap(true).SetText("Loading...")
+	view.SetBorder(true).SetTitle(" " + id + " ")
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' {
+			luc.pages.RemovePage("coin")
+			luc.cviewApp.SetFocus(previous)
+			return nil
+		}
+		return event
+	})
+	luc.pages.AddPage("coin", view, true, true)
+	luc.cviewApp.SetFocus(view)
+
+	go func() {
+		c, err := coingecko.FetchCoin(id, coingecko.DefaultCoinOptions)
+		luc.cviewApp.QueueUpdateDraw(func() {
+			if err != nil {
+				view.SetText("Failed to load " + id + ": " + err.Error())
+				return
+			}
+			view.SetTitle(" " + c.Name + " ")
+			view.SetText(luc.coinText(c)).ScrollToBeginning()
+		})
+	}()
+}
+
+// coinTicker returns the coin's ticker in a Yahoo symbol such as BTC-USD.
+func coinTicker(symbol string) string {
+	return strings.ToUpper(strings.SplitN(symbol, "-", 2)[0])
+}
+
+// showCoinFor is showCoin for a Yahoo crypto symbol. When several coins
+// share the ticker the user picks one, and the pick is remembered.
+func (luc *Lucrum) showCoinFor(symbol string) {
+	ticker := coinTicker(symbol)
+	if id, ok := luc.conf.CoinIDs[ticker]; ok {
+		luc.showCoin(id)
+		return
+	}
+
+	luc.setStatus("Looking up %s on CoinGecko", ticker)
+	go func() {
+		candidates, err := luc.coins.Rank(ticker)
+		luc.cviewApp.QueueUpdateDraw(func() {
+			if err != nil {
+				luc.setStatus("%s", err)
+				return
+			}
+			luc.setStatus("%s", luc.flagSummary())
+			if len(candidates) == 1 {
+				luc.showCoin(candidates[0].ID)
+				return
+			}
+			luc.pickCoin(ticker, candidates, func(id string) {
+				if luc.conf.CoinIDs == nil {
+					luc.conf.CoinIDs = make(map[string]string)
+				}
+				luc.conf.CoinIDs[ticker] = id
+				if err := luc.saveConfig(); err != nil {
+					luc.setStatus("%s", err)
+				}
+				luc.showCoin(id)
+			})
+		})
+	}()
+}
+
+// pickCoin lists the coins sharing a ticker, largest first, and calls done
+// with the ID of the one picked.
+func (luc *Lucrum) pickCoin(ticker string, candidates []coingecko.Candidate, done func(id string)) {
+	closePicker := func() {
+		luc.pages.RemovePage("pick")
+		luc.cviewApp.SetFocus(luc.stockTable)
+	}
+	list := cview.NewList()
+	list.SetBorder(true).SetTitle(" Which " + ticker + "? ")
+	for _, c := range candidates {
+		id := c.ID
+		detail := "no market data"
+		if c.MarketCapRank > 0 {
+			detail = fmt.Sprintf("#%d, market cap %s", c.MarketCapRank, format.AbbreviateCash(float64(c.MarketCap)))
+		}
+		list.AddItem(c.Name+" ("+c.ID+")", detail, 0, func() {
+			closePicker()
+			done(id)
+		})
+	}
+	list.SetDoneFunc(closePicker)
+
+	width, height := 60, 2*len(candidates)+2
+	if height > 22 {
+		height = 22
+	}
+	overlay := cview.NewGrid().SetColumns(0, width, 0).SetRows(0, height, 0).
+		AddItem(list, 1, 1, 1, 1, 0, 0, true)
+	luc.pages.AddPage("pick", overlay, true, true)
+	luc.cviewApp.SetFocus(list)
+}
@@ -0,0 +1,38 @@
+package lucrum
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/anorb/lucrum/pkg/coingecko"
+)
+
+func TestCoinText(t *testing.T) {
+	for env, value := range map[string]string{"LC_ALL": "", "LC_MESSAGES": "", "LANG": "de_DE.UTF-8"} {
+		env := env
+		old, ok := os.LookupEnv(env)
+		os.Setenv(env, value)
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(env, old)
+			} else {
+				os.Unsetenv(env)
+			}
+		})
+	}
+
+	luc := &Lucrum{}
+	c := coingecko.CoinResponse{
+		Name:         "Bitcoin",
+		Symbol:       "btc",
+		Localization: coingecko.Localization{"en": "Bitcoin", "de": "Bitcoin (de)"},
+	}
+	text := luc.coinText(c)
+	if !strings.Contains(text, "Bitcoin (de) (BTC)") {
+		t.Errorf("name not localized:\n%s", text)
+	}
+	if strings.Contains(text, "rank #0") {
+		t.Errorf("missing CoinGecko rank shown:\n%s", text)
+	}
+}
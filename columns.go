@@ -0,0 +1,325 @@
+package lucrum
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/fetch"
+	"github.com/anorb/lucrum/pkg/format"
+	"github.com/anorb/lucrum/pkg/indicators"
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+)
+
+const candleInterval = 15 * time.Minute
+
+type column struct {
+	header string
+	value  func(luc *Lucrum, s yahoofinance.Stock) string
+	// number is used for sorting, columns without it sort by their text
+	number func(luc *Lucrum, s yahoofinance.Stock) float64
+}
+
+var defaultColumns = []string{"Symbol", "Current", "Change", "Change%", "High", "Low", "Open", "Volume", "Mkt Cap"}
+
+var stockColumns = map[string]column{
+	"Symbol": {"Symbol", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return s.Symbol
+	}, nil},
+	"Current": {fmt.Sprintf("%15s", "Current"), func(luc *Lucrum, s yahoofinance.Stock) string {
+		return s.FormattedRegularMarketPrice
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return s.RegularMarketPrice
+	}},
+	"Change": {"Change", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return luc.glyph(s.RegularMarketChange) + s.FormattedRegularMarketChange
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return s.RegularMarketChange
+	}},
+	"Change%": {"Change%", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return luc.glyph(s.RegularMarketChange) + s.FormattedRegularMarketChangePct
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return s.RegularMarketChangePercent
+	}},
+	"High": {"High", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return s.FormattedRegularMarketDayHigh
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return s.RegularMarketDayHigh
+	}},
+	"Low": {"Low", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return s.FormattedRegularMarketDayLow
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return s.RegularMarketDayLow
+	}},
+	"Open": {"Open", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return s.FormattedRegularMarketDayOpen
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return s.RegularMarketOpen
+	}},
+	"Volume": {"Volume", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return s.FormattedRegularMarketVolume
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return float64(s.RegularMarketVolume)
+	}},
+	"Mkt Cap": {"Mkt Cap", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return s.FormattedMarketCap
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return float64(s.MarketCap)
+	}},
+	"50D Avg": {"50D Avg", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return s.FormatPrice(s.FiftyDayAverage)
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return s.FiftyDayAverage
+	}},
+	"200D Avg": {"200D Avg", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return s.FormatPrice(s.TwoHundredDayAverage)
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return s.TwoHundredDayAverage
+	}},
+	"Div Yield": {"Div Yield", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return yieldText(luc.trailingYield(s))
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return luc.trailingYield(s)
+	}},
+	"Fwd Yield": {"Fwd Yield", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return yieldText(luc.forwardYield(s))
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return luc.forwardYield(s)
+	}},
+	"YoC": {"YoC", func(luc *Lucrum, s yahoofinance.Stock) string {
+		return yieldText(luc.yieldOnCost(s))
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return luc.yieldOnCost(s)
+	}},
+	"Ex-Div": {"Ex-Div", func(luc *Lucrum, s yahoofinance.Stock) string {
+		ex, _ := luc.nextDividend(s)
+		if ex.IsZero() {
+			return ""
+		}
+		return ex.Format("Jan 02")
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		ex, _ := luc.nextDividend(s)
+		if ex.IsZero() {
+			return math.NaN()
+		}
+		return float64(ex.Unix())
+	}},
+}
+
+// dividendColumns need the dividend history of the watchlist.
+var dividendColumns = map[string]bool{"Div Yield": true, "Fwd Yield": true, "YoC": true, "Ex-Div": true}
+
+// dateColumns hold a Unix time as their number, which raw exports write as
+// a date.
+var dateColumns = map[string]bool{"Ex-Div": true}
+
+// yieldText leaves yields of symbols that don't pay blank.
+func yieldText(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return format.Percentage(v)
+}
+
+func (luc *Lucrum) initColumns(names []string) error {
+	if len(names) == 0 {
+		names = defaultColumns
+	}
+	luc.columns = nil
+	luc.usesDaily, luc.usesSession, luc.usesDividends = false, false, false
+	for _, name := range names {
+		if c, ok := stockColumns[name]; ok {
+			luc.columns = append(luc.columns, c)
+			if dividendColumns[name] {
+				luc.usesDividends = true
+			}
+			continue
+		}
+		spec, err := indicators.ParseSpec(name)
+		if err != nil {
+			return err
+		}
+		luc.columns = append(luc.columns, indicatorColumn(spec))
+		if spec.Intraday() {
+			luc.usesSession = true
+		} else {
+			luc.usesDaily = true
+		}
+	}
+	return nil
+}
+
+func indicatorColumn(spec indicators.Spec) column {
+	candles := func(luc *Lucrum, symbol string) []indicators.Candle {
+		if spec.Intraday() {
+			return luc.sessions[symbol]
+		}
+		return luc.candles[symbol]
+	}
+	return column{spec.String(), func(luc *Lucrum, s yahoofinance.Stock) string {
+		v := spec.Value(candles(luc, s.Symbol))
+		if math.IsNaN(v) {
+			return ""
+		}
+		if spec.Name == "RSI" {
+			return format.Number(v, 2)
+		}
+		return format.Number(v, s.Precision())
+	}, func(luc *Lucrum, s yahoofinance.Stock) float64 {
+		return spec.Value(candles(luc, s.Symbol))
+	}}
+}
+
+func (c column) name() string {
+	return strings.TrimSpace(c.header)
+}
+
+// findColumn matches a column by name, ignoring case.
+func (luc *Lucrum) findColumn(name string) (column, bool) {
+	for _, c := range luc.columns {
+		if strings.EqualFold(c.name(), name) {
+			return c, true
+		}
+	}
+	return column{}, false
+}
+
+// candlesDue reports whether the indicator columns need new candles.
+func (luc *Lucrum) candlesDue() bool {
+	return (luc.usesDaily || luc.usesSession) && luc.now().Sub(luc.lastCandleUpdate) >= candleInterval
+}
+
+// columnData is what the indicator and dividend columns are computed from,
+// by symbol: a year of daily candles, the intraday candles of the last
+// session and the dividend history.
+type columnData struct {
+	daily, session map[string][]indicators.Candle
+	dividends      map[string][]yahoofinance.Dividend
+}
+
+// refreshColumnData fetches candles and dividend histories in the
+// background once they're due, updating the table when they arrive. Only
+// one fetch runs at a time.
+func (luc *Lucrum) refreshColumnData(symbols []string) {
+	if luc.fetchingColumnData {
+		return
+	}
+	candles := luc.candlesDue()
+	dividends := luc.dividendsDue()
+	if !candles && !dividends {
+		return
+	}
+	var stale []string
+	if dividends {
+		stale = luc.staleDividends(symbols)
+	}
+	luc.fetchingColumnData = true
+	go func() {
+		data := luc.fetchColumnData(symbols, candles, stale)
+		luc.cviewApp.QueueUpdateDraw(func() {
+			luc.fetchingColumnData = false
+			luc.stockMutex.Lock()
+			defer luc.stockMutex.Unlock()
+			luc.updateColumnData(data)
+			if candles {
+				luc.lastCandleUpdate = luc.now()
+			}
+			if dividends {
+				luc.lastDividendCheck = luc.now()
+			}
+			luc.updateStockRows()
+		})
+	}()
+}
+
+// fetchColumnData fetches the candles the columns need for symbols if
+// candles is set, and the dividend histories of stale, a few symbols at a
+// time, skipping any that fail. The daily chart carries the dividends too,
+// so stale symbols with one don't need another request.
+func (luc *Lucrum) fetchColumnData(symbols []string, candles bool, stale []string) columnData {
+	data := columnData{
+		make(map[string][]indicators.Candle),
+		make(map[string][]indicators.Candle),
+		make(map[string][]yahoofinance.Dividend),
+	}
+	// stale is always among symbols
+	all := stale
+	if candles {
+		all = symbols
+	}
+	isStale := make(map[string]bool)
+	for _, sym := range stale {
+		isStale[sym] = true
+	}
+	var mutex sync.Mutex
+	fetch.Each(fetch.Chunk(all, 1, 0), func(chunk []string) error {
+		sym := chunk[0]
+		var daily, session []indicators.Candle
+		var history []yahoofinance.Dividend
+		gotHistory := false
+		if candles && luc.usesDaily {
+			if chart, err := luc.quotes.FetchChart(sym, "1y", "1d"); err == nil {
+				daily, history, gotHistory = candlesOf(chart), chart.Dividends, true
+			}
+		}
+		if candles && luc.usesSession {
+			session, _ = luc.fetchSession(sym)
+		}
+		if isStale[sym] && !gotHistory {
+			if chart, err := luc.quotes.FetchChart(sym, dividendHistory, "1mo"); err == nil {
+				history, gotHistory = chart.Dividends, true
+			}
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		if daily != nil {
+			data.daily[sym] = daily
+		}
+		if session != nil {
+			data.session[sym] = session
+		}
+		if gotHistory {
+			data.dividends[sym] = history
+		}
+		return nil
+	})
+	return data
+}
+
+func (luc *Lucrum) updateColumnData(data columnData) {
+	for sym, candles := range data.daily {
+		luc.candles[sym] = candles
+	}
+	for sym, candles := range data.session {
+		luc.sessions[sym] = candles
+	}
+	luc.updateDividends(data.dividends, luc.now())
+}
+
+func (luc *Lucrum) fetchCandles(symbol string) ([]indicators.Candle, error) {
+	return luc.fetchChartCandles(symbol, "1y", "1d")
+}
+
+// fetchSession fetches five minute candles of the current session, or the
+// last one while the market is closed.
+func (luc *Lucrum) fetchSession(symbol string) ([]indicators.Candle, error) {
+	return luc.fetchChartCandles(symbol, "1d", "5m")
+}
+
+func (luc *Lucrum) fetchChartCandles(symbol, chartRange, interval string) ([]indicators.Candle, error) {
+	chart, err := luc.quotes.FetchChart(symbol, chartRange, interval)
+	if err != nil {
+		return nil, err
+	}
+	return candlesOf(chart), nil
+}
+
+func candlesOf(chart yahoofinance.Chart) []indicators.Candle {
+	candles := make([]indicators.Candle, len(chart.Candles))
+	for i, c := range chart.Candles {
+		candles[i] = indicators.Candle(c)
+	}
+	return candles
+}
@@ -0,0 +1,364 @@
+package lucrum
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+const maxHistory = 100
+
+// command is run from the command line opened with ':'. complete returns
+// candidates for the argument being typed, given the arguments before it.
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string) error
+	complete    func(args []string) []string
+}
+
+func (luc *Lucrum) initCommands() {
+	luc.commands = make(map[string]command)
+	for _, c := range []command{
+		{"add", "add SYMBOL...", "Add symbols to the watchlist", luc.addCommand, nil},
+		{"rm", "rm SYMBOL... [# GROUP]", "Remove symbols and a group from the watchlist", luc.rmCommand, luc.completeSymbols},
+		{"sort", "sort COLUMN [asc|desc] | sort none", "Sort the table by a column", luc.sortCommand, luc.completeSort},
+		{"list", "list TYPE | list all", "Only show one type of quote, e.g. crypto or etf", luc.listCommand, luc.completeList},
+		{"alert", "alert SYMBOL > PRICE | alert clear [SYMBOL]", "Ring when a price crosses a level", luc.alertCommand, luc.completeSymbols},
+		{"export", "export [raw] FILE", "Save the table as .csv, .json, .md or .html", luc.exportCommand, nil},
+		{"interval", "interval DURATION", "Set how often quotes refresh, e.g. 30s", luc.intervalCommand, nil},
+		{"help", "help", "Show keys and commands", func(args []string) error {
+			luc.showHelp()
+			return nil
+		}, nil},
+		{"quit", "quit", "Quit", func(args []string) error {
+			luc.cviewApp.Stop()
+			return nil
+		}, nil},
+	} {
+		luc.registerCommand(c)
+	}
+}
+
+// registerCommand makes a command available on the command line.
+func (luc *Lucrum) registerCommand(c command) {
+	luc.commands[c.name] = c
+}
+
+func (luc *Lucrum) commandNames() []string {
+	var names []string
+	for name := range luc.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (luc *Lucrum) runCommand(line string) error {
+	fields := strings.Fields(stripSuggestions(line))
+	if len(fields) == 0 {
+		return nil
+	}
+	c, ok := luc.commands[fields[0]]
+	if !ok {
+		return errors.New("Unknown command: " + fields[0])
+	}
+	return c.run(fields[1:])
+}
+
+// commandLine opens the command line, optionally starting with text.
+func (luc *Lucrum) commandLine(text string) {
+	historyPos := len(luc.history)
+	entries := 0
+
+	input := luc.prompt(":", func(line string) {
+		luc.addHistory(line)
+		if err := luc.runCommand(line); err != nil {
+			luc.setStatus("%s", err)
+		}
+	})
+	input.SetAutocompleteFunc(func(current string) []string {
+		completions := luc.completeCommand(input, current)
+		entries = len(completions)
+		return completions
+	})
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// Up and Down walk the history unless a completion list is open
+		if entries > 0 || len(luc.history) == 0 {
+			return event
+		}
+		switch event.Key() {
+		case tcell.KeyUp:
+			if historyPos > 0 {
+				historyPos--
+			}
+			input.SetText(luc.history[historyPos])
+			return nil
+		case tcell.KeyDown:
+			if historyPos < len(luc.history)-1 {
+				historyPos++
+				input.SetText(luc.history[historyPos])
+			} else {
+				historyPos = len(luc.history)
+				input.SetText("")
+			}
+			return nil
+		}
+		return event
+	})
+	input.SetText(text)
+}
+
+func (luc *Lucrum) completeCommand(input *cview.InputField, text string) []string {
+	if text == "" {
+		return nil
+	}
+	fields := strings.Fields(text)
+	trailingSpace := strings.HasSuffix(text, " ")
+	if len(fields) == 1 && !trailingSpace {
+		var matches []string
+		for _, name := range luc.commandNames() {
+			if strings.HasPrefix(name, fields[0]) && name != fields[0] {
+				matches = append(matches, name+" ")
+			}
+		}
+		return matches
+	}
+
+	c, ok := luc.commands[fields[0]]
+	if !ok {
+		return nil
+	}
+	if c.name == "add" {
+		return luc.autocomplete(input, text)
+	}
+	if c.complete == nil {
+		return nil
+	}
+
+	args := fields[1:]
+	partial := ""
+	if !trailingSpace && len(args) > 0 {
+		partial = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	prefix := strings.TrimSuffix(text, partial)
+	var matches []string
+	for _, candidate := range c.complete(args) {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(partial)) && !strings.EqualFold(candidate, partial) {
+			matches = append(matches, prefix+candidate)
+		}
+	}
+	return matches
+}
+
+func (luc *Lucrum) completeSymbols(args []string) []string {
+	if len(args) > 0 && args[0] == "clear" {
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		return nil
+	}
+	return luc.quoteSymbols()
+}
+
+func (luc *Lucrum) completeSort(args []string) []string {
+	switch len(args) {
+	case 0:
+		names := []string{"none"}
+		for _, c := range luc.columns {
+			names = append(names, strings.ToLower(c.name()))
+		}
+		return names
+	case 1:
+		return []string{"asc", "desc"}
+	}
+	return nil
+}
+
+func (luc *Lucrum) completeList(args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	names := []string{"all"}
+	for name := range quoteTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (luc *Lucrum) addCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("Usage: add SYMBOL...")
+	}
+	luc.addSymbols(args)
+	return nil
+}
+
+// rmCommand removes symbols, and a group named after a "#", e.g.
+// "rm AAPL # Big Tech".
+func (luc *Lucrum) rmCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("Usage: rm SYMBOL... [# GROUP]")
+	}
+	for i, arg := range args {
+		if isGroup(arg) {
+			args = append(args[:i:i], groupPrefix+groupName(strings.Join(args[i:], " ")))
+			break
+		}
+	}
+	luc.removeSymbols(args)
+	return nil
+}
+
+func (luc *Lucrum) sortCommand(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("Usage: sort COLUMN [asc|desc] | sort none")
+	}
+	if args[0] == "none" {
+		luc.sortColumn = ""
+		luc.updateStockRows()
+		return nil
+	}
+	col, ok := luc.findColumn(args[0])
+	if !ok {
+		return errors.New("No such column: " + args[0])
+	}
+	desc := false
+	if len(args) == 2 {
+		switch args[1] {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return errors.New("Sort order must be asc or desc")
+		}
+	}
+	luc.sortColumn = col.name()
+	luc.sortDesc = desc
+	luc.updateStockRows()
+	return nil
+}
+
+func (luc *Lucrum) listCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: list TYPE | list all")
+	}
+	if args[0] == "all" {
+		luc.listType = ""
+	} else if t, ok := quoteTypes[strings.ToLower(args[0])]; ok {
+		luc.listType = t
+	} else {
+		return errors.New("Unknown type: " + args[0])
+	}
+	luc.updateStockRows()
+	return nil
+}
+
+func (luc *Lucrum) alertCommand(args []string) error {
+	if len(args) == 0 {
+		var alerts []string
+		for _, a := range luc.alerts {
+			alerts = append(alerts, a.String())
+		}
+		if len(alerts) == 0 {
+			luc.setStatus("No alerts")
+		} else {
+			luc.setStatus("Alerts: %s", strings.Join(alerts, "; "))
+		}
+		return nil
+	}
+	if args[0] == "clear" {
+		var remaining []alert
+		for _, a := range luc.alerts {
+			if len(args) > 1 && !strings.EqualFold(a.symbol, args[1]) {
+				remaining = append(remaining, a)
+			}
+		}
+		luc.alerts = remaining
+		return luc.saveAlerts()
+	}
+
+	a, err := parseAlert(args)
+	if err != nil {
+		return err
+	}
+	// Only the watchlist is quoted, so an alert on anything else never fires
+	if _, ok := luc.stock(a.symbol); !ok || isGroup(a.symbol) {
+		return errors.New(a.symbol + " is not in the watchlist, add it first")
+	}
+	luc.alerts = append(luc.alerts, a)
+	if err := luc.saveAlerts(); err != nil {
+		return err
+	}
+	luc.setStatus("Alert set: %s", a)
+	return nil
+}
+
+func (luc *Lucrum) intervalCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: interval DURATION")
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		return errors.New("Invalid interval: " + args[0])
+	}
+	if d < minInterval {
+		return errors.New("Interval must be at least a second")
+	}
+	luc.updateInterval = d
+	luc.conf.Interval = d.String()
+	if err := luc.saveConfig(); err != nil {
+		return err
+	}
+	luc.setStatus("Refreshing every %s", d)
+	return nil
+}
+
+func (luc *Lucrum) historyPath() string {
+	return filepath.Join(filepath.Dir(luc.configPath), "history")
+}
+
+func (luc *Lucrum) loadHistory() {
+	f, err := os.Open(luc.historyPath())
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			luc.history = append(luc.history, line)
+		}
+	}
+}
+
+func (luc *Lucrum) addHistory(line string) {
+	line = strings.TrimSpace(stripSuggestions(line))
+	if line == "" || (len(luc.history) > 0 && luc.history[len(luc.history)-1] == line) {
+		return
+	}
+	luc.history = append(luc.history, line)
+	if len(luc.history) > maxHistory {
+		luc.history = luc.history[len(luc.history)-maxHistory:]
+	}
+
+	f, err := os.Create(luc.historyPath())
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	for _, l := range luc.history {
+		fmt.Fprintln(f, l)
+	}
+}
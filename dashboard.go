@@ -0,0 +1,191 @@
+package lucrum
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/coingecko"
+	"github.com/anorb/lucrum/pkg/format"
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+const (
+	dashboardColumns = 5
+	dashboardCoins   = 5
+	tileHeight       = 4
+
+	// CoinGecko allows far fewer requests than Yahoo
+	coinRefresh = time.Minute
+)
+
+type tile struct {
+	symbol string
+	label  string
+}
+
+// The Yahoo quotes on the dashboard, one row of tiles per section. The top
+// coins by market cap follow them.
+var dashboardSections = [][]tile{
+	{{"^GSPC", "S&P 500"}, {"^DJI", "Dow Jones"}, {"^IXIC", "Nasdaq"}, {"^RUT", "Russell 2000"}, {"^VIX", "VIX"}},
+	{{"ES=F", "S&P futures"}, {"NQ=F", "Nasdaq futures"}, {"YM=F", "Dow futures"}, {"RTY=F", "Russell futures"}},
+	{{"^TNX", "10Y yield"}, {"GC=F", "Gold"}, {"CL=F", "Crude oil"}, {"DX-Y.NYB", "US dollar index"}},
+}
+
+type dashboard struct {
+	grid      *cview.Grid
+	tiles     map[string]*cview.TextView
+	coinTiles []*cview.TextView
+	lastCoins time.Time
+	fetching  bool
+	visible   bool
+}
+
+func newTile(label string) *cview.TextView {
+	t := cview.NewTextView().SetTextAlign(cview.AlignCenter)
+	t.SetBorder(true).SetTitle(" " + label + " ")
+	return t
+}
+
+func (luc *Lucrum) initDashboard() {
+	d := &dashboard{
+		grid:  cview.NewGrid(),
+		tiles: make(map[string]*cview.TextView),
+	}
+	row := 0
+	add := func(t *cview.TextView, i int) {
+		d.grid.AddItem(t, row+i/dashboardColumns, i%dashboardColumns, 1, 1, 0, 0, false)
+	}
+	for _, section := range dashboardSections {
+		for i, t := range section {
+			d.tiles[t.symbol] = newTile(t.label)
+			add(d.tiles[t.symbol], i)
+		}
+		row += (len(section) + dashboardColumns - 1) / dashboardColumns
+	}
+	for i := 0; i < dashboardCoins; i++ {
+		t := newTile("Coin")
+		d.coinTiles = append(d.coinTiles, t)
+		add(t, i)
+	}
+	row += (dashboardCoins + dashboardColumns - 1) / dashboardColumns
+
+	rows := make([]int, row+1)
+	for i := 0; i < row; i++ {
+		rows[i] = tileHeight
+	}
+	columns := make([]int, dashboardColumns)
+	d.grid.SetRows(rows...).SetColumns(columns...)
+	d.grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape || event.Rune() == 'q' || event.Rune() == 'd':
+			luc.toggleDashboard()
+		case event.Rune() == 'u':
+			luc.refreshDashboard()
+		default:
+			return event
+		}
+		return nil
+	})
+	luc.dashboard = d
+}
+
+// toggleDashboard swaps the watchlist table for the dashboard and back. The
+// status line and prompts stay underneath either of them.
+func (luc *Lucrum) toggleDashboard() {
+	d := luc.dashboard
+	if d.visible {
+		luc.grid.RemoveItem(d.grid)
+		luc.grid.AddItem(luc.stockTable, 0, 0, 1, 1, 0, 0, true)
+		luc.cviewApp.SetFocus(luc.stockTable)
+	} else {
+		luc.grid.RemoveItem(luc.stockTable)
+		luc.grid.AddItem(d.grid, 0, 0, 1, 1, 0, 0, true)
+		luc.cviewApp.SetFocus(d.grid)
+		luc.refreshDashboard()
+	}
+	d.visible = !d.visible
+}
+
+// refreshDashboard fetches the dashboard's quotes in the background.
+func (luc *Lucrum) refreshDashboard() {
+	d := luc.dashboard
+	if d.fetching {
+		return
+	}
+	d.fetching = true
+
+	var symbols []string
+	for _, section := range dashboardSections {
+		for _, t := range section {
+			symbols = append(symbols, t.symbol)
+		}
+	}
+	fetchCoins := luc.now().Sub(d.lastCoins) >= coinRefresh
+
+	currency := luc.currency()
+	go func() {
+		quotes, err := luc.quotes.FetchQuote(symbols)
+		var coins []coingecko.MarketsResponse
+		var coinErr error
+		if fetchCoins {
+			coins, coinErr = coingecko.FetchMarkets(coingecko.MarketsOptions{
+				VsCurrency:            currency,
+				PerPage:               dashboardCoins,
+				PriceChangePercentage: []string{"24h"},
+			})
+		}
+
+		luc.cviewApp.QueueUpdateDraw(func() {
+			d.fetching = false
+			for _, s := range quotes {
+				if t, ok := d.tiles[s.Symbol]; ok {
+					luc.drawQuoteTile(t, s)
+				}
+			}
+			if fetchCoins && coinErr == nil {
+				d.lastCoins = luc.now()
+				for i, c := range coins {
+					if i < len(d.coinTiles) {
+						luc.drawCoinTile(d.coinTiles[i], c)
+					}
+				}
+			}
+			if err != nil {
+				luc.setStatus("Dashboard update failed: %s", err)
+			} else if coinErr != nil {
+				luc.setStatus("Coin update failed: %s", coinErr)
+			}
+		})
+	}()
+}
+
+func (luc *Lucrum) drawQuoteTile(t *cview.TextView, s yahoofinance.Stock) {
+	price, change := s.FormattedRegularMarketPrice, s.FormattedRegularMarketChange
+	// Indices, yields and the dollar index aren't priced in dollars
+	if s.QuoteType == "INDEX" {
+		price = format.Number(s.RegularMarketPrice, s.Precision())
+		change = format.Number(s.RegularMarketChange, s.Precision())
+	}
+	luc.drawTile(t, price, change, s.RegularMarketChangePercent)
+}
+
+func (luc *Lucrum) drawCoinTile(t *cview.TextView, c coingecko.MarketsResponse) {
+	t.SetTitle(fmt.Sprintf(" %s %s ", strings.ToUpper(c.Symbol), c.Name))
+	precision := format.Precision(c.CurrentPrice, 2)
+	currency := luc.currency()
+	luc.drawTile(t, format.Money(c.CurrentPrice, precision, currency), format.Money(c.PriceChange24H, precision, currency), c.PriceChangePercentage24H)
+}
+
+func (luc *Lucrum) drawTile(t *cview.TextView, price, change string, percent float64) {
+	color := luc.theme.neutral
+	if percent > 0 {
+		color = luc.theme.up
+	} else if percent < 0 {
+		color = luc.theme.down
+	}
+	t.SetTextColor(color)
+	t.SetText(fmt.Sprintf("%s\n%s%s (%s)", price, luc.glyph(percent), change, format.Percentage(percent)))
+}
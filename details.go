@@ -0,0 +1,98 @@
+package lucrum
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/format"
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+func (luc *Lucrum) detailsText(symbol string) (string, bool) {
+	s, ok := luc.stock(symbol)
+	if !ok {
+		return "", false
+	}
+
+	var b strings.Builder
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%-14s %s\n", label, value)
+		}
+	}
+	name := s.LongName
+	if name == "" {
+		name = s.ShortName
+	}
+	line("Name", name)
+	exchange := s.FullExchangeName
+	if s.Exchange != "" && s.Exchange != exchange {
+		exchange += " (" + s.Exchange + ")"
+	}
+	line("Exchange", strings.TrimSpace(exchange))
+	line("Type", s.QuoteType)
+	line("Currency", s.Currency)
+	line("Market state", s.MarketState)
+	if s.RegularMarketTime > 0 {
+		line("Last trade", time.Unix(int64(s.RegularMarketTime), 0).Format("2006-01-02 15:04"))
+	}
+	b.WriteString("\n")
+	line("Price", s.FormattedRegularMarketPrice)
+	line("Change", s.FormattedRegularMarketChange+" ("+s.FormattedRegularMarketChangePct+")")
+	line("Previous close", s.FormatPrice(s.RegularMarketPreviousClose))
+	line("Open", s.FormattedRegularMarketDayOpen)
+	line("Day range", s.FormattedRegularMarketDayLow+" - "+s.FormattedRegularMarketDayHigh)
+	line("52 week range", s.FormatPrice(s.FiftyTwoWeekLow)+" - "+s.FormatPrice(s.FiftyTwoWeekHigh))
+	line("50 day avg", s.FormatPrice(s.FiftyDayAverage))
+	line("200 day avg", s.FormatPrice(s.TwoHundredDayAverage))
+	b.WriteString("\n")
+	line("Volume", s.FormattedRegularMarketVolume)
+	line("Avg volume", format.Abbreviate(float64(s.AverageDailyVolume3Month)))
+	line("Market cap", s.FormattedMarketCap)
+	if s.TrailingPE != 0 {
+		line("P/E", format.Number(s.TrailingPE, 2))
+	}
+	if s.EpsTrailingTwelveMonths != 0 {
+		line("EPS", format.Number(s.EpsTrailingTwelveMonths, 2))
+	}
+	if reason, ok := luc.flags[symbol]; ok {
+		b.WriteString("\n")
+		line("Warning", reason)
+	}
+	if s.QuoteType == "CRYPTOCURRENCY" {
+		b.WriteString("\nPress i for more from CoinGecko\n")
+	}
+	return b.String(), true
+}
+
+// showDetails shows everything known about a symbol over the watchlist.
+func (luc *Lucrum) showDetails(symbol string) {
+	text, ok := luc.detailsText(symbol)
+	if !ok {
+		return
+	}
+	view := cview.NewTextView().SetText(text)
+	view.SetBorder(true).SetTitle(" " + symbol + " ")
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyEnter || event.Rune() == 'q' {
+			luc.pages.RemovePage("details")
+			luc.cviewApp.SetFocus(luc.stockTable)
+			return nil
+		}
+		if s, _ := luc.stock(symbol); event.Rune() == 'i' && s.QuoteType == "CRYPTOCURRENCY" {
+			luc.pages.RemovePage("details")
+			luc.cviewApp.SetFocus(luc.stockTable)
+			luc.showCoinFor(symbol)
+			return nil
+		}
+		return event
+	})
+
+	width, height := 60, strings.Count(text, "\n")+2
+	overlay := cview.NewGrid().SetColumns(0, width, 0).SetRows(0, height, 0).
+		AddItem(view, 1, 1, 1, 1, 0, 0, true)
+	luc.pages.AddPage("details", overlay, true, true)
+	luc.cviewApp.SetFocus(view)
+}
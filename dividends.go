@@ -0,0 +1,415 @@
+package lucrum
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/fetch"
+	"github.com/anorb/lucrum/pkg/format"
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+const (
+	// Dividends are announced weeks ahead, so a twice daily check is plenty
+	dividendInterval = 12 * time.Hour
+	// Enough history to tell how often a symbol pays
+	dividendHistory = "2y"
+	calendarMonths  = 12
+	// Pay dates further than this from the ex-date are taken as stale
+	maxPayLag = 90 * 24 * time.Hour
+)
+
+// dividends is a symbol's dividend history from Yahoo's chart events.
+type dividends struct {
+	history []yahoofinance.Dividend
+	fetched time.Time
+}
+
+// payment is an expected dividend payment for the holdings of a symbol.
+type payment struct {
+	symbol string
+	date   time.Time
+	amount float64
+}
+
+// sumHoldings sums the holdings of each symbol across accounts. The cost
+// is only known if it's known for every account.
+func sumHoldings(holdings []holding) map[string]holding {
+	held := make(map[string]holding)
+	unknownCost := make(map[string]bool)
+	for _, h := range holdings {
+		total := held[h.Symbol]
+		total.Symbol = h.Symbol
+		total.Shares += h.Shares
+		total.Cost += h.Cost
+		if h.Cost == 0 {
+			unknownCost[h.Symbol] = true
+		}
+		held[h.Symbol] = total
+	}
+	for sym := range unknownCost {
+		total := held[sym]
+		total.Cost = 0
+		held[sym] = total
+	}
+	return held
+}
+
+// setHoldings replaces the holdings and their totals by symbol, which are
+// kept as the dividend columns need them for every cell.
+func (luc *Lucrum) setHoldings(holdings []holding) {
+	luc.conf.Holdings = holdings
+	luc.held = sumHoldings(holdings)
+}
+
+// heldSymbols returns the symbols of the holdings, sorted.
+func (luc *Lucrum) heldSymbols() []string {
+	var symbols []string
+	for sym := range luc.held {
+		symbols = append(symbols, sym)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// quoteFor returns the latest quote of a symbol, from the watchlist or
+// fetched for the income view.
+func (luc *Lucrum) quoteFor(symbol string) (yahoofinance.Stock, bool) {
+	if s, ok := luc.stock(symbol); ok && s.RegularMarketPrice != 0 {
+		return s, true
+	}
+	s, ok := luc.heldQuotes[symbol]
+	return s, ok
+}
+
+// paymentsPerYear works out how often a symbol pays from the typical gap
+// between its last few dividends: 1, 2, 4, 12 or 52 times a year.
+func paymentsPerYear(history []yahoofinance.Dividend) int {
+	if len(history) < 2 {
+		return len(history)
+	}
+	start := len(history) - 5
+	if start < 0 {
+		start = 0
+	}
+	var gaps []float64
+	for i := start + 1; i < len(history); i++ {
+		gaps = append(gaps, history[i].Date.Sub(history[i-1].Date).Hours()/24)
+	}
+	sort.Float64s(gaps)
+	gap := gaps[len(gaps)/2]
+
+	best := 1
+	for _, n := range []int{1, 2, 4, 12, 52} {
+		if math.Abs(365/float64(n)-gap) < math.Abs(365/float64(best)-gap) {
+			best = n
+		}
+	}
+	return best
+}
+
+// trailingDividends sums the dividends of the year up to now.
+func trailingDividends(history []yahoofinance.Dividend, now time.Time) float64 {
+	sum := 0.0
+	for _, d := range history {
+		if d.Date.After(now.AddDate(-1, 0, 0)) && !d.Date.After(now) {
+			sum += d.Amount
+		}
+	}
+	return sum
+}
+
+// forwardRate is the dividend per share expected over the next year,
+// Yahoo's figure or else the last dividend carried forward.
+func forwardRate(s yahoofinance.Stock, history []yahoofinance.Dividend) float64 {
+	if s.DividendRate > 0 {
+		return s.DividendRate
+	}
+	if len(history) == 0 {
+		return 0
+	}
+	return history[len(history)-1].Amount * float64(paymentsPerYear(history))
+}
+
+func (luc *Lucrum) trailingYield(s yahoofinance.Stock) float64 {
+	if history := luc.dividends[s.Symbol].history; len(history) > 0 && s.RegularMarketPrice > 0 {
+		return trailingDividends(history, luc.now()) / s.RegularMarketPrice * 100
+	}
+	// Yahoo gives this one as a fraction
+	return s.TrailingAnnualDividendYield * 100
+}
+
+func (luc *Lucrum) forwardYield(s yahoofinance.Stock) float64 {
+	if s.RegularMarketPrice <= 0 {
+		return 0
+	}
+	return forwardRate(s, luc.dividends[s.Symbol].history) / s.RegularMarketPrice * 100
+}
+
+// yieldOnCost is the forward dividend over what the holdings cost, zero if
+// the cost isn't known.
+func (luc *Lucrum) yieldOnCost(s yahoofinance.Stock) float64 {
+	h, ok := luc.held[s.Symbol]
+	if !ok || h.Cost <= 0 {
+		return 0
+	}
+	return forwardRate(s, luc.dividends[s.Symbol].history) * h.Shares / h.Cost * 100
+}
+
+// nextDividend returns the next ex-dividend and pay dates, Yahoo's if it
+// has announced them, otherwise projected from the history. Either is zero
+// if unknown.
+func (luc *Lucrum) nextDividend(s yahoofinance.Stock) (ex, pay time.Time) {
+	now := luc.now()
+	history := luc.dividends[s.Symbol].history
+	lag := payLag(s)
+
+	if s.ExDividendDate > 0 && time.Unix(s.ExDividendDate, 0).After(now) {
+		ex = time.Unix(s.ExDividendDate, 0)
+	} else if n := paymentsPerYear(history); n > 0 {
+		ex = history[len(history)-1].Date
+		for !ex.After(now) {
+			ex = nextPayment(ex, n)
+		}
+	}
+	// Yahoo's pay date belongs to its last ex-date, which may be past
+	if s.DividendDate > 0 && !ex.IsZero() && time.Unix(s.DividendDate, 0).After(ex) {
+		pay = time.Unix(s.DividendDate, 0)
+	} else if !ex.IsZero() {
+		pay = ex.Add(lag)
+	}
+	return ex, pay
+}
+
+// payLag is how long after the ex-date the symbol pays, going by the last
+// dates Yahoo gave, or zero.
+func payLag(s yahoofinance.Stock) time.Duration {
+	if s.DividendDate == 0 || s.ExDividendDate == 0 {
+		return 0
+	}
+	lag := time.Unix(s.DividendDate, 0).Sub(time.Unix(s.ExDividendDate, 0))
+	if lag < 0 || lag > maxPayLag {
+		return 0
+	}
+	return lag
+}
+
+func nextPayment(t time.Time, perYear int) time.Time {
+	if perYear >= 52 {
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 12/perYear, 0)
+}
+
+// expectedPayments projects the dividends of the holdings of a symbol over
+// the coming months, at the forward rate.
+func (luc *Lucrum) expectedPayments(s yahoofinance.Stock, shares float64, months int) []payment {
+	history := luc.dividends[s.Symbol].history
+	perYear := paymentsPerYear(history)
+	rate := forwardRate(s, history)
+	if perYear == 0 || rate == 0 {
+		return nil
+	}
+
+	end := luc.now().AddDate(0, months, 0)
+	_, pay := luc.nextDividend(s)
+	var payments []payment
+	for ; !pay.IsZero() && pay.Before(end); pay = nextPayment(pay, perYear) {
+		payments = append(payments, payment{s.Symbol, pay, rate / float64(perYear) * shares})
+	}
+	return payments
+}
+
+// staleDividends returns the symbols whose dividend history is due.
+func (luc *Lucrum) staleDividends(symbols []string) []string {
+	var stale []string
+	for _, sym := range symbols {
+		if luc.now().Sub(luc.dividends[sym].fetched) >= dividendInterval {
+			stale = append(stale, sym)
+		}
+	}
+	return stale
+}
+
+// dividendsDue reports whether the dividend columns should check for stale
+// histories. Failed fetches are tried again as often as candles.
+func (luc *Lucrum) dividendsDue() bool {
+	return luc.usesDividends && luc.now().Sub(luc.lastDividendCheck) >= candleInterval
+}
+
+// fetchHistories fetches dividend histories a few at a time, keeping the
+// ones that worked when others fail.
+func (luc *Lucrum) fetchHistories(symbols []string) (map[string][]yahoofinance.Dividend, error) {
+	histories := make(map[string][]yahoofinance.Dividend)
+	var mutex sync.Mutex
+	err := fetch.Each(fetch.Chunk(symbols, 1, 0), func(chunk []string) error {
+		chart, err := luc.quotes.FetchChart(chunk[0], dividendHistory, "1mo")
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		histories[chunk[0]] = chart.Dividends
+		mutex.Unlock()
+		return nil
+	})
+	return histories, err
+}
+
+func (luc *Lucrum) updateDividends(histories map[string][]yahoofinance.Dividend, fetched time.Time) {
+	for sym, history := range histories {
+		luc.dividends[sym] = dividends{history, fetched}
+	}
+}
+
+// fetchDividends fetches the dividend history of the holdings, and quotes
+// of holdings missing from the watchlist, then calls done on the UI.
+func (luc *Lucrum) fetchDividends(done func()) {
+	now := luc.now()
+	stale := luc.staleDividends(luc.heldSymbols())
+	var unquoted []string
+	for _, sym := range luc.heldSymbols() {
+		if _, ok := luc.stock(sym); !ok {
+			unquoted = append(unquoted, sym)
+		}
+	}
+	if len(stale) == 0 && len(unquoted) == 0 {
+		done()
+		return
+	}
+
+	go func() {
+		histories, err := luc.fetchHistories(stale)
+		var quotes []yahoofinance.Stock
+		if len(unquoted) > 0 {
+			var quoteErr error
+			if quotes, quoteErr = luc.quotes.FetchQuote(unquoted); quoteErr != nil {
+				err = quoteErr
+			}
+		}
+
+		luc.cviewApp.QueueUpdateDraw(func() {
+			luc.updateDividends(histories, now)
+			for _, s := range quotes {
+				luc.heldQuotes[s.Symbol] = s
+			}
+			if err != nil {
+				luc.setStatus("Dividend update failed: %s", err)
+			}
+			luc.updateStockRows()
+			done()
+		})
+	}()
+}
+
+// incomeText is the income calendar: the income expected each month, then
+// the yields of each holding.
+func (luc *Lucrum) incomeText() string {
+	held := luc.held
+	symbols := luc.heldSymbols()
+	now := luc.now()
+
+	months := make([]float64, calendarMonths)
+	payers := make([][]string, calendarMonths)
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	var total, trailing, value, cost, costValue float64
+	for _, sym := range symbols {
+		s, ok := luc.quoteFor(sym)
+		if !ok {
+			continue
+		}
+		h := held[sym]
+		for _, p := range luc.expectedPayments(s, h.Shares, calendarMonths) {
+			m := (p.date.Year()-start.Year())*12 + int(p.date.Month()-start.Month())
+			if m < 0 || m >= calendarMonths {
+				continue
+			}
+			months[m] += p.amount
+			payers[m] = append(payers[m], fmt.Sprintf("%s %s", sym, format.Cash(p.amount, 2)))
+		}
+		annual := forwardRate(s, luc.dividends[sym].history) * h.Shares
+		total += annual
+		trailing += trailingDividends(luc.dividends[sym].history, now) * h.Shares
+		value += s.RegularMarketPrice * h.Shares
+		if h.Cost > 0 {
+			cost += h.Cost
+			costValue += annual
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Forward income %s a year, %s a month\n", format.Cash(total, 2), format.Cash(total/12, 2))
+	fmt.Fprintf(&b, "Trailing income %s over the last year at today's shares\n", format.Cash(trailing, 2))
+	if value > 0 {
+		fmt.Fprintf(&b, "Forward yield %s", format.Percentage(total/value*100))
+		if cost > 0 {
+			fmt.Fprintf(&b, ", yield on cost %s", format.Percentage(costValue/cost*100))
+		}
+		b.WriteString("\n")
+	}
+
+	largest := 0.0
+	for _, m := range months {
+		largest = math.Max(largest, m)
+	}
+	b.WriteString("\n")
+	for i, amount := range months {
+		bar := ""
+		if largest > 0 {
+			bar = strings.Repeat("█", int(math.Round(amount/largest*20)))
+		}
+		fmt.Fprintf(&b, "%-8s %12s  %-20s  %s\n", start.AddDate(0, i, 0).Format("Jan 2006"), format.Cash(amount, 2), bar, strings.Join(payers[i], ", "))
+	}
+
+	fmt.Fprintf(&b, "\n%-10s %10s %9s %9s %9s %10s %10s %12s\n", "Symbol", "Shares", "Trailing", "Forward", "On cost", "Ex-div", "Pay", "Income/yr")
+	for _, sym := range symbols {
+		h := held[sym]
+		s, ok := luc.quoteFor(sym)
+		if !ok {
+			fmt.Fprintf(&b, "%-10s %10s  no quote\n", sym, shares(h.Shares))
+			continue
+		}
+		ex, pay := luc.nextDividend(s)
+		fmt.Fprintf(&b, "%-10s %10s %9s %9s %9s %10s %10s %12s\n", sym, shares(h.Shares),
+			yieldText(luc.trailingYield(s)), yieldText(luc.forwardYield(s)), yieldText(luc.yieldOnCost(s)),
+			date(ex), date(pay), format.Cash(forwardRate(s, luc.dividends[sym].history)*h.Shares, 2))
+	}
+	return b.String()
+}
+
+func date(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02")
+}
+
+// showIncome shows the income calendar of the holdings.
+func (luc *Lucrum) showIncome() {
+	if len(luc.conf.Holdings) == 0 {
+		luc.setStatus("No holdings, add them with lucrum import")
+		return
+	}
+	view := cview.NewTextView()
+	view.SetBorder(true).SetTitle(" Dividend income ")
+	view.SetText("Fetching dividends...")
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' {
+			luc.pages.RemovePage("income")
+			luc.cviewApp.SetFocus(luc.stockTable)
+			return nil
+		}
+		return event
+	})
+
+	luc.pages.AddPage("income", view, true, true)
+	luc.cviewApp.SetFocus(view)
+	luc.fetchDividends(func() {
+		view.SetText(luc.incomeText())
+	})
+}
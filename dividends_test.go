@@ -0,0 +1,148 @@
+package lucrum
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func history(dates ...time.Time) []yahoofinance.Dividend {
+	var h []yahoofinance.Dividend
+	for _, d := range dates {
+		h = append(h, yahoofinance.Dividend{Date: d, Amount: 1})
+	}
+	return h
+}
+
+func TestPaymentsPerYear(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []yahoofinance.Dividend
+		want    int
+	}{
+		{"none", nil, 0},
+		{"one", history(day(2023, 6, 1)), 1},
+		{"annual", history(day(2022, 6, 1), day(2023, 6, 3)), 1},
+		{"semiannual", history(day(2023, 1, 10), day(2023, 7, 12), day(2024, 1, 9)), 2},
+		{"quarterly with a late one", history(day(2023, 2, 14), day(2023, 6, 14), day(2023, 9, 14), day(2023, 11, 30), day(2024, 2, 14)), 4},
+		{"monthly", history(day(2024, 1, 2), day(2024, 2, 1), day(2024, 3, 1), day(2024, 4, 1)), 12},
+		{"weekly", history(day(2024, 1, 5), day(2024, 1, 12), day(2024, 1, 19)), 52},
+	}
+	for _, test := range tests {
+		if got := paymentsPerYear(test.history); got != test.want {
+			t.Errorf("%s: got %d payments a year, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestIncome(t *testing.T) {
+	ko := quote("KO", 60)
+	ko.DividendRate = 1.94
+	ko.ExDividendDate = day(2024, 2, 14).Unix()
+	ko.DividendDate = day(2024, 4, 1).Unix()
+	tel := quote("T", 17)
+	tel.DividendRate = 1.11
+
+	quotes := newFakeQuotes(ko, tel)
+	quotes.setChart("KO", yahoofinance.Chart{Dividends: []yahoofinance.Dividend{
+		{Date: day(2023, 2, 14), Amount: 0.46},
+		{Date: day(2023, 6, 14), Amount: 0.46},
+		{Date: day(2023, 9, 14), Amount: 0.46},
+		{Date: day(2023, 11, 30), Amount: 0.46},
+		{Date: day(2024, 2, 14), Amount: 0.485},
+	}})
+	h := newHarness(t, `Symbols = ["KO"]
+Columns = ["Symbol", "Div Yield", "Fwd Yield", "YoC", "Ex-Div"]
+
+[[Holdings]]
+Symbol = "KO"
+Shares = 100.0
+Cost = 5000.0
+
+[[Holdings]]
+Symbol = "T"
+Account = "IRA"
+Shares = 10.0
+`, quotes)
+	h.do(func() { h.screen.SetSize(120, 40) })
+	h.tick(time.Second)
+
+	// The dividend columns fetch the history on refresh
+	h.waitFor("the dividend history", func() bool { return h.luc.dividends["KO"].history != nil })
+	if line := h.line("KO"); !strings.Contains(line, "3.11%") || !strings.Contains(line, "May 14") {
+		t.Errorf("dividend columns wrong before the calendar: %q\n%s", line, h.text())
+	}
+
+	h.press("D")
+	h.waitFor("the income calendar", func() bool {
+		_, ok := h.luc.heldQuotes["T"]
+		return ok
+	})
+	text := h.text()
+	for _, want := range []string{
+		"Forward income $205.10 a year",
+		"Trailing income $186.50",
+		"yield on cost 3.88%",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("no %q in:\n%s", want, text)
+		}
+	}
+	for month, want := range map[string]string{"Apr 2024": "$0.00", "Jun 2024": "$48.50", "Sep 2024": "$48.50", "Dec 2024": "$48.50", "Feb 2025": "$0.00"} {
+		if line := h.line(month); !strings.Contains(line, want) {
+			t.Errorf("%s: got %q, want %s", month, line, want)
+		}
+	}
+	if line := h.line("KO "); !strings.Contains(line, "3.11%") || !strings.Contains(line, "3.23%") || !strings.Contains(line, "2024-05-14") || !strings.Contains(line, "2024-06-30") {
+		t.Errorf("KO yields or dates wrong: %q", line)
+	}
+	// T isn't on the watchlist, its quote is fetched for the calendar
+	if line := h.line("T "); !strings.Contains(line, "6.53%") || !strings.Contains(line, "$11.10") {
+		t.Errorf("T row wrong: %q", line)
+	}
+
+	h.press("q")
+	if line := h.line("KO"); !strings.Contains(line, "3.11%") || !strings.Contains(line, "3.23%") || !strings.Contains(line, "3.88%") || !strings.Contains(line, "May 14") {
+		t.Errorf("dividend columns wrong: %q\n%s", line, h.text())
+	}
+
+	// The history isn't fetched again until it's stale
+	fetched := h.luc.dividends["KO"].fetched
+	h.tick(time.Hour)
+	h.press("D")
+	h.press("q")
+	if got := h.luc.dividends["KO"].fetched; !got.Equal(fetched) {
+		t.Errorf("history fetched again at %s", got)
+	}
+}
+
+func TestDividendsFromDailyChart(t *testing.T) {
+	quotes := newFakeQuotes(quote("KO", 60))
+	quotes.setChart("KO", yahoofinance.Chart{Dividends: []yahoofinance.Dividend{
+		{Date: day(2023, 6, 14), Amount: 0.46},
+		{Date: day(2023, 9, 14), Amount: 0.46},
+		{Date: day(2023, 11, 30), Amount: 0.46},
+		{Date: day(2024, 2, 14), Amount: 0.485},
+	}})
+	h := newHarness(t, `Symbols = ["KO"]
+Columns = ["Symbol", "SMA(5)", "Div Yield"]`, quotes)
+	h.tick(time.Second)
+	h.waitFor("the dividend history", func() bool { return h.luc.dividends["KO"].history != nil })
+
+	// One chart request gives both the candles and the dividends
+	quotes.mutex.Lock()
+	calls := strings.Join(quotes.chartCalls, ", ")
+	quotes.mutex.Unlock()
+	if calls != "KO 1y 1d" {
+		t.Errorf("chart requests %s", calls)
+	}
+	if line := h.line("KO"); !strings.Contains(line, "3.11%") {
+		t.Errorf("dividend yield wrong: %q", line)
+	}
+}
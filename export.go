@@ -0,0 +1,319 @@
+package lucrum
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/fetch"
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+)
+
+// exportFormats are the formats Export writes, keyed by name.
+var exportFormats = map[string]func(w io.Writer, t exportTable) error{
+	"csv":  writeCSV,
+	"json": writeJSON,
+	"md":   writeMarkdown,
+	"html": writeHTML,
+}
+
+// exportFormatNames maps file extensions and aliases to exportFormats.
+var exportFormatNames = map[string]string{
+	"csv":      "csv",
+	"json":     "json",
+	"md":       "md",
+	"markdown": "md",
+	"html":     "html",
+	"htm":      "html",
+}
+
+// FormatOf returns the export format for a file name or format name, e.g.
+// "report.md" or "markdown".
+func FormatOf(name string) (string, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if ext == "" {
+		ext = strings.ToLower(name)
+	}
+	if f, ok := exportFormatNames[ext]; ok {
+		return f, nil
+	}
+	return "", errors.New("Unknown export format: " + name + " (csv, json, md or html)")
+}
+
+// exportTable is the table as shown, or with unformatted numbers if raw.
+// Group separators have no cells, only a group name.
+type exportTable struct {
+	headers []string
+	rows    []exportRow
+	raw     bool
+	stocks  []yahoofinance.Stock
+}
+
+type exportRow struct {
+	group string
+	cells []string
+}
+
+func (luc *Lucrum) exportTable(raw bool) exportTable {
+	t := exportTable{raw: raw}
+	for _, c := range luc.columns {
+		t.headers = append(t.headers, c.name())
+	}
+	for _, i := range luc.view {
+		s := luc.stocks[i]
+		if isGroup(s.Symbol) {
+			t.rows = append(t.rows, exportRow{group: groupName(s.Symbol)})
+			continue
+		}
+		t.stocks = append(t.stocks, s)
+		cells := luc.rowCells(s)
+		if raw {
+			for key, col := range luc.columns {
+				if col.number == nil {
+					continue
+				}
+				cells[key] = rawNumber(col, col.number(luc, s))
+			}
+		}
+		t.rows = append(t.rows, exportRow{cells: cells})
+	}
+	return t
+}
+
+// rawNumber writes a column's number unformatted, or as an ISO date for
+// date columns. Missing values, which columns give as NaN, are left blank.
+func rawNumber(col column, v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return ""
+	}
+	if dateColumns[col.name()] {
+		return time.Unix(int64(v), 0).UTC().Format("2006-01-02")
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Export writes the rows shown, in their order, as csv, json, md or html.
+// Values are formatted as in the table unless raw is set, in which case
+// numbers are written unformatted and JSON holds the whole quotes.
+func (luc *Lucrum) Export(w io.Writer, format string, raw bool) error {
+	write, ok := exportFormats[format]
+	if !ok {
+		return errors.New("Unknown export format: " + format)
+	}
+	luc.stockMutex.Lock()
+	luc.updateView()
+	t := luc.exportTable(raw)
+	luc.stockMutex.Unlock()
+	return write(w, t)
+}
+
+// Fetch quotes the watchlist once, for use without the interface. Unlike a
+// refresh it doesn't fire alerts or wait for the update interval.
+func (luc *Lucrum) Fetch() error {
+	symbols := luc.quoteSymbols()
+	stocks, err := luc.quotes.FetchQuote(symbols)
+	var batch *fetch.BatchError
+	if err != nil && !(errors.As(err, &batch) && len(stocks) > 0) {
+		return err
+	}
+	var stale []string
+	if luc.usesDividends {
+		stale = luc.staleDividends(symbols)
+	}
+	luc.updateColumnData(luc.fetchColumnData(symbols, luc.usesDaily || luc.usesSession, stale))
+
+	fetched := make(map[string]yahoofinance.Stock)
+	for _, s := range stocks {
+		fetched[s.Symbol] = s
+	}
+	luc.stockMutex.Lock()
+	for i, s := range luc.stocks {
+		if f, ok := fetched[s.Symbol]; ok {
+			luc.stocks[i] = f
+		}
+	}
+	luc.stockMutex.Unlock()
+	luc.quotesTime = luc.now()
+	luc.cached = false
+	return err
+}
+
+// Command runs a command line command such as "sort Change% desc".
+func (luc *Lucrum) Command(line string) error {
+	return luc.runCommand(line)
+}
+
+func (luc *Lucrum) exportCommand(args []string) error {
+	raw := len(args) > 0 && args[0] == "raw"
+	if raw {
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return errors.New("Usage: export [raw] FILE")
+	}
+	path := args[0]
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := luc.Export(f, format, raw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	luc.setStatus("Exported %d rows to %s", len(luc.view), path)
+	return nil
+}
+
+func (luc *Lucrum) exportPrompt() {
+	luc.commandLine("export ")
+}
+
+func writeCSV(w io.Writer, t exportTable) error {
+	c := csv.NewWriter(w)
+	if err := c.Write(t.headers); err != nil {
+		return err
+	}
+	for _, r := range t.rows {
+		if r.group != "" {
+			continue
+		}
+		if err := c.Write(r.cells); err != nil {
+			return err
+		}
+	}
+	c.Flush()
+	return c.Error()
+}
+
+// writeJSON writes an array of objects keyed by column, in column order, or
+// the quotes themselves if raw.
+func writeJSON(w io.Writer, t exportTable) error {
+	if t.raw {
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		if t.stocks == nil {
+			t.stocks = []yahoofinance.Stock{}
+		}
+		return e.Encode(t.stocks)
+	}
+
+	var b strings.Builder
+	b.WriteString("[")
+	first := true
+	for _, r := range t.rows {
+		if r.group != "" {
+			continue
+		}
+		if !first {
+			b.WriteString(",")
+		}
+		first = false
+		b.WriteString("\n  {")
+		for i, h := range t.headers {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			key, _ := json.Marshal(h)
+			value, _ := json.Marshal(r.cells[i])
+			fmt.Fprintf(&b, "%s: %s", key, value)
+		}
+		b.WriteString("}")
+	}
+	if !first {
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMarkdown writes a table with the symbol left aligned and numbers
+// right aligned. Groups become bold rows.
+func writeMarkdown(w io.Writer, t exportTable) error {
+	var b strings.Builder
+	row := func(cells []string) {
+		b.WriteString("|")
+		for _, c := range cells {
+			fmt.Fprintf(&b, " %s |", strings.ReplaceAll(c, "|", `\|`))
+		}
+		b.WriteString("\n")
+	}
+	row(t.headers)
+	b.WriteString("|")
+	for i := range t.headers {
+		if i == 0 {
+			b.WriteString(" --- |")
+		} else {
+			b.WriteString(" ---: |")
+		}
+	}
+	b.WriteString("\n")
+	for _, r := range t.rows {
+		if r.group != "" {
+			cells := make([]string, len(t.headers))
+			cells[0] = "**" + r.group + "**"
+			row(cells)
+			continue
+		}
+		row(r.cells)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeHTML writes a standalone page that can be opened or mailed as is.
+func writeHTML(w io.Writer, t exportTable) error {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Watchlist</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 0.8em; text-align: right; border-bottom: 1px solid #ddd; }
+th:first-child, td:first-child { text-align: left; }
+tr.group td { text-align: left; font-weight: bold; }
+</style>
+</head>
+<body>
+<table>
+<thead>
+<tr>`)
+	for _, h := range t.headers {
+		fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(h))
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, r := range t.rows {
+		if r.group != "" {
+			fmt.Fprintf(&b, "<tr class=\"group\"><td colspan=\"%d\">%s</td></tr>\n", len(t.headers), html.EscapeString(r.group))
+			continue
+		}
+		b.WriteString("<tr>")
+		for _, c := range r.cells {
+			fmt.Fprintf(&b, "<td>%s</td>", html.EscapeString(c))
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
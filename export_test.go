@@ -0,0 +1,97 @@
+package lucrum
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExport(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87), quote("MSFT", 402.5))
+	h := newHarness(t, `Symbols = ["# Tech", "AAPL", "MSFT"]
+Columns = ["Symbol", "Current"]`, quotes)
+	h.tick(time.Second)
+
+	tests := []struct {
+		format string
+		raw    bool
+		sort   string
+		want   string
+	}{
+		{"csv", false, "", "Symbol,Current\nAAPL,$172.87\nMSFT,$402.50\n"},
+		{"csv", true, "current desc", "Symbol,Current\nMSFT,402.5\nAAPL,172.87\n"},
+		{"json", false, "", "[\n  {\"Symbol\": \"AAPL\", \"Current\": \"$172.87\"},\n  {\"Symbol\": \"MSFT\", \"Current\": \"$402.50\"}\n]\n"},
+		{"md", false, "", "| Symbol | Current |\n| --- | ---: |\n| **Tech** |  |\n| AAPL | $172.87 |\n| MSFT | $402.50 |\n"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		var err error
+		h.do(func() {
+			sort := "none"
+			if tt.sort != "" {
+				sort = tt.sort
+			}
+			if err = h.luc.Command("sort " + sort); err == nil {
+				err = h.luc.Export(&b, tt.format, tt.raw)
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s raw=%v sort=%q:\ngot\n%s\nwant\n%s", tt.format, tt.raw, tt.sort, b.String(), tt.want)
+		}
+	}
+
+	var b bytes.Buffer
+	h.do(func() { h.luc.Export(&b, "html", false) })
+	if page := b.String(); !strings.Contains(page, `<tr class="group"><td colspan="2">Tech</td></tr>`) || !strings.Contains(page, "<td>$172.87</td>") {
+		t.Errorf("got page\n%s", page)
+	}
+}
+
+func TestExportRaw(t *testing.T) {
+	flat := quote("KO", 60)
+	flat.ExDividendDate = day(2024, 3, 14).Unix()
+	quotes := newFakeQuotes(flat, quote("T", 17))
+	h := newHarness(t, `Symbols = ["KO", "T"]
+Columns = ["Symbol", "Change", "SMA(5)", "Ex-Div"]`, quotes)
+	h.tick(time.Second)
+
+	var b bytes.Buffer
+	var err error
+	h.do(func() { err = h.luc.Export(&b, "csv", true) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Symbol,Change,SMA(5),Ex-Div\nKO,0,,2024-03-14\nT,0,,\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+
+	// Rows without a date sort last either way
+	for _, order := range []string{"asc", "desc"} {
+		b.Reset()
+		h.do(func() {
+			if err = h.luc.Command("sort ex-div " + order); err == nil {
+				err = h.luc.Export(&b, "csv", true)
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != want {
+			t.Errorf("sorted %s, got\n%s\nwant\n%s", order, b.String(), want)
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{"report.CSV": "csv", "snap.md": "md", "markdown": "md", "page.htm": "html", "json": "json", "notes.txt": ""}
+	for name, want := range tests {
+		if got, _ := FormatOf(name); got != want {
+			t.Errorf("FormatOf(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
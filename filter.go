@@ -0,0 +1,138 @@
+package lucrum
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+	"github.com/gdamore/tcell"
+)
+
+// The numeric fields a filter can compare, e.g. "chg<-2" or "cap>10B".
+var filterNumbers = map[string]func(s yahoofinance.Stock) float64{
+	"price":  func(s yahoofinance.Stock) float64 { return s.RegularMarketPrice },
+	"chg":    func(s yahoofinance.Stock) float64 { return s.RegularMarketChangePercent },
+	"change": func(s yahoofinance.Stock) float64 { return s.RegularMarketChange },
+	"vol":    func(s yahoofinance.Stock) float64 { return float64(s.RegularMarketVolume) },
+	"cap":    func(s yahoofinance.Stock) float64 { return float64(s.MarketCap) },
+	"pe":     func(s yahoofinance.Stock) float64 { return s.TrailingPE },
+}
+
+// The text fields a filter can match, e.g. "exchange:NMS" or "type:etf".
+var filterFields = map[string]func(s yahoofinance.Stock) []string{
+	"exchange": func(s yahoofinance.Stock) []string { return []string{s.Exchange, s.FullExchangeName} },
+	"type":     func(s yahoofinance.Stock) []string { return []string{s.QuoteType} },
+	"currency": func(s yahoofinance.Stock) []string { return []string{s.Currency} },
+	"market":   func(s yahoofinance.Stock) []string { return []string{s.Market} },
+}
+
+var suffixMultipliers = map[byte]float64{'K': 1e3, 'M': 1e6, 'B': 1e9, 'T': 1e12}
+
+type filterTerm func(s yahoofinance.Stock) bool
+
+// parseFilter turns the filter text into terms that must all match. Words
+// that aren't predicates match the symbol, short name or long name.
+func parseFilter(text string) []filterTerm {
+	var terms []filterTerm
+	for _, word := range strings.Fields(text) {
+		if term, ok := predicate(word); ok {
+			terms = append(terms, term)
+			continue
+		}
+		needle := strings.ToLower(word)
+		terms = append(terms, func(s yahoofinance.Stock) bool {
+			return strings.Contains(strings.ToLower(s.Symbol), needle) ||
+				strings.Contains(strings.ToLower(s.ShortName), needle) ||
+				strings.Contains(strings.ToLower(s.LongName), needle)
+		})
+	}
+	return terms
+}
+
+func predicate(word string) (filterTerm, bool) {
+	if i := strings.Index(word, ":"); i > 0 {
+		field, ok := filterFields[strings.ToLower(word[:i])]
+		if !ok {
+			return nil, false
+		}
+		want := strings.ToLower(word[i+1:])
+		if t, ok := quoteTypes[want]; ok && strings.ToLower(word[:i]) == "type" {
+			want = strings.ToLower(t)
+		}
+		return func(s yahoofinance.Stock) bool {
+			for _, v := range field(s) {
+				if strings.ToLower(v) == want {
+					return true
+				}
+			}
+			return false
+		}, true
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		i := strings.Index(word, op)
+		if i <= 0 {
+			continue
+		}
+		number, ok := filterNumbers[strings.ToLower(word[:i])]
+		if !ok {
+			return nil, false
+		}
+		value, ok := parseAmount(word[i+len(op):])
+		if !ok {
+			return nil, false
+		}
+		cmp := alert{op: op, value: value}
+		return func(s yahoofinance.Stock) bool {
+			if op == "=" {
+				return number(s) == value
+			}
+			return cmp.triggered(number(s))
+		}, true
+	}
+	return nil, false
+}
+
+// parseAmount reads numbers with an optional K/M/B/T suffix.
+func parseAmount(s string) (float64, bool) {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "$"), "%")
+	multiplier := 1.0
+	if len(s) > 0 {
+		if m, ok := suffixMultipliers[strings.ToUpper(s[len(s)-1:])[0]]; ok {
+			multiplier = m
+			s = s[:len(s)-1]
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * multiplier, true
+}
+
+func (luc *Lucrum) matchesFilter(s yahoofinance.Stock) bool {
+	for _, term := range luc.filter {
+		if !term(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func (luc *Lucrum) setFilter(text string) {
+	luc.filterText = strings.TrimSpace(text)
+	luc.filter = parseFilter(luc.filterText)
+	luc.updateStockRows()
+	luc.drawStatus()
+}
+
+func (luc *Lucrum) filterPrompt() {
+	input := luc.prompt("/", func(text string) {})
+	input.SetText(luc.filterText)
+	input.SetChangedFunc(luc.setFilter)
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			luc.setFilter("")
+		}
+	})
+}
@@ -5,5 +5,6 @@ go 1.14
 require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/gdamore/tcell v1.3.0
+	gitlab.com/tslocum/cbind v0.1.1
 	gitlab.com/tslocum/cview v1.4.7
 )
@@ -0,0 +1,154 @@
+package lucrum
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/anorb/lucrum/pkg/brokers"
+)
+
+// Share counts this close to zero are rounding left over from a sale
+const dust = 1e-9
+
+// holding is shares of a symbol held in an account, saved in the config as
+// [[Holdings]] tables.
+type holding struct {
+	Symbol  string
+	Account string `toml:",omitempty"`
+	Shares  float64
+	// Total cost basis in the quote's currency, zero if unknown
+	Cost float64 `toml:",omitempty"`
+}
+
+type holdingKey struct {
+	account, symbol string
+}
+
+func (h holding) key() holdingKey {
+	return holdingKey{h.Account, h.Symbol}
+}
+
+// mergeHoldings applies a broker statement to the holdings. Positions are
+// a snapshot, so they replace everything held in the accounts they cover.
+// Transactions are applied in date order, buys adding to the cost and sales
+// taking their share of it away. Buys and sells already in imported are
+// left out, so importing an export again changes nothing, and the ones
+// applied are added to it. A statement with both only uses its positions,
+// the transactions being how they came about. account is used for rows
+// without one. It returns notes on anything left out.
+func mergeHoldings(old []holding, imported map[string]bool, s brokers.Statement, account string) ([]holding, []string) {
+	var notes []string
+	orAccount := func(a string) string {
+		if a == "" {
+			return account
+		}
+		return a
+	}
+
+	merged := make(map[holdingKey]*holding)
+	var order []holdingKey
+	add := func(h holding) *holding {
+		k := h.key()
+		if existing, ok := merged[k]; ok {
+			existing.Shares += h.Shares
+			existing.Cost += h.Cost
+			return existing
+		}
+		merged[k] = &h
+		order = append(order, k)
+		return &h
+	}
+
+	if len(s.Positions) > 0 {
+		replaced := make(map[string]bool)
+		for _, p := range s.Positions {
+			replaced[orAccount(p.Account)] = true
+		}
+		for _, h := range old {
+			if !replaced[h.Account] {
+				add(h)
+			}
+		}
+		for _, p := range s.Positions {
+			add(holding{Symbol: p.Symbol, Account: orAccount(p.Account), Shares: p.Quantity, Cost: p.CostBasis})
+		}
+		if len(s.Transactions) > 0 {
+			notes = append(notes, fmt.Sprintf("transactions left out: %d, the positions are current", len(s.Transactions)))
+		}
+	} else {
+		for _, h := range old {
+			add(h)
+		}
+		transactions := append([]brokers.Transaction(nil), s.Transactions...)
+		sort.SliceStable(transactions, func(i, j int) bool {
+			return transactions[i].Date.Before(transactions[j].Date)
+		})
+		dividends, repeated := 0, 0
+		seen := make(map[string]int)
+		for _, t := range transactions {
+			k := holdingKey{orAccount(t.Account), t.Symbol}
+			if t.Action == brokers.Buy || t.Action == brokers.Sell {
+				id := transactionID(k.account, t)
+				// Identical rows in one export are told apart by their count
+				if seen[id]++; seen[id] > 1 {
+					id += fmt.Sprintf(" #%d", seen[id])
+				}
+				if imported[id] {
+					repeated++
+					continue
+				}
+				imported[id] = true
+			}
+			switch t.Action {
+			case brokers.Buy:
+				cost := math.Abs(t.Amount)
+				if cost == 0 {
+					cost = t.Quantity * t.Price
+				}
+				add(holding{Symbol: t.Symbol, Account: k.account, Shares: t.Quantity, Cost: cost})
+			case brokers.Sell:
+				h, ok := merged[k]
+				if !ok || h.Shares < t.Quantity-dust {
+					notes = append(notes, fmt.Sprintf("%s %s sold more than held, the rest may be in an earlier export", t.Date.Format("2006-01-02"), t.Symbol))
+				}
+				if !ok {
+					continue
+				}
+				if h.Shares > t.Quantity {
+					h.Cost *= (h.Shares - t.Quantity) / h.Shares
+					h.Shares -= t.Quantity
+				} else {
+					h.Shares, h.Cost = 0, 0
+				}
+			case brokers.Dividend:
+				dividends++
+			}
+		}
+		if repeated > 0 {
+			notes = append(notes, fmt.Sprintf("transactions left out: %d, imported before", repeated))
+		}
+		if dividends > 0 {
+			notes = append(notes, fmt.Sprintf("dividends left out: %d, lucrum gets them from Yahoo", dividends))
+		}
+	}
+
+	var holdings []holding
+	for _, k := range order {
+		if h := merged[k]; h.Shares > dust {
+			holdings = append(holdings, *h)
+		}
+	}
+	sort.SliceStable(holdings, func(i, j int) bool {
+		if holdings[i].Account != holdings[j].Account {
+			return holdings[i].Account < holdings[j].Account
+		}
+		return holdings[i].Symbol < holdings[j].Symbol
+	})
+	return holdings, notes
+}
+
+// transactionID identifies a buy or sell in the config's imported list.
+func transactionID(account string, t brokers.Transaction) string {
+	return fmt.Sprintf("%s %s %s %s %s %s", t.Date.Format("2006-01-02"), account, t.Action, t.Symbol, shares(t.Quantity), shares(t.Amount))
+}
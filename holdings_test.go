@@ -0,0 +1,123 @@
+package lucrum
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/brokers"
+)
+
+func TestMergeHoldings(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 2, d, 0, 0, 0, 0, time.UTC) }
+	old := []holding{
+		{Symbol: "AAPL", Account: "IRA", Shares: 10, Cost: 1500},
+		{Symbol: "IBM", Account: "Taxable", Shares: 5, Cost: 600},
+		{Symbol: "MSFT", Account: "Taxable", Shares: 4, Cost: 1000},
+	}
+	tests := []struct {
+		name      string
+		statement brokers.Statement
+		want      string
+		notes     int
+	}{
+		{
+			name: "positions replace their account",
+			statement: brokers.Statement{Positions: []brokers.Position{
+				{Account: "Taxable", Symbol: "MSFT", Quantity: 6, CostBasis: 1800},
+				{Account: "Taxable", Symbol: "VTI", Quantity: 2, CostBasis: 400},
+			}},
+			want: "IRA AAPL 10 1500, Taxable MSFT 6 1800, Taxable VTI 2 400",
+		},
+		{
+			name: "positions without an account",
+			statement: brokers.Statement{Positions: []brokers.Position{
+				{Symbol: "SCHD", Quantity: 3},
+			}},
+			want: "Broker SCHD 3 0, IRA AAPL 10 1500, Taxable IBM 5 600, Taxable MSFT 4 1000",
+		},
+		{
+			name: "transactions in date order",
+			statement: brokers.Statement{Transactions: []brokers.Transaction{
+				{Account: "Taxable", Date: day(20), Action: brokers.Sell, Symbol: "MSFT", Quantity: 3, Amount: 1200},
+				{Account: "Taxable", Date: day(10), Action: brokers.Buy, Symbol: "MSFT", Quantity: 4, Amount: -1400},
+				{Account: "Taxable", Date: day(12), Action: brokers.Buy, Symbol: "O", Quantity: 10, Price: 50},
+				{Account: "Taxable", Date: day(15), Action: brokers.Sell, Symbol: "IBM", Quantity: 5},
+				{Account: "Taxable", Date: day(15), Action: brokers.Dividend, Symbol: "O", Amount: 2.5},
+			}},
+			want:  "IRA AAPL 10 1500, Taxable MSFT 5 1500, Taxable O 10 500",
+			notes: 1,
+		},
+		{
+			name: "selling more than held",
+			statement: brokers.Statement{Transactions: []brokers.Transaction{
+				{Account: "IRA", Date: day(1), Action: brokers.Sell, Symbol: "AAPL", Quantity: 12},
+				{Account: "IRA", Date: day(2), Action: brokers.Sell, Symbol: "NVDA", Quantity: 1},
+			}},
+			want:  "Taxable IBM 5 600, Taxable MSFT 4 1000",
+			notes: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, notes := mergeHoldings(old, make(map[string]bool), tt.statement, "Broker")
+			var out []string
+			for _, h := range got {
+				out = append(out, fmt.Sprintf("%s %s %g %g", h.Account, h.Symbol, h.Shares, h.Cost))
+			}
+			if strings.Join(out, ", ") != tt.want {
+				t.Errorf("got %s\nwant %s", strings.Join(out, ", "), tt.want)
+			}
+			if len(notes) != tt.notes {
+				t.Errorf("notes %q, want %d", notes, tt.notes)
+			}
+		})
+	}
+}
+
+func TestImportTwice(t *testing.T) {
+	const activity = `"Date","Action","Symbol","Description","Quantity","Price","Fees & Comm","Amount"
+"03/01/2024","Buy","SCHD","SCHWAB US DIVIDEND EQUITY ETF","10","$76.50","","-$765.00"
+"03/01/2024","Buy","SCHD","SCHWAB US DIVIDEND EQUITY ETF","10","$76.50","","-$765.00"
+"02/28/2024","Buy","NOPE","NO SUCH FUND","1","$10.00","","-$10.00"
+"02/20/2024","Sell","MSFT","MICROSOFT CORP","2","$405.00","$0.05","$809.95"
+`
+	quotes := newFakeQuotes(quote("MSFT", 402.5), quote("SCHD", 76.5))
+	h := newHarness(t, `Symbols = ["MSFT"]
+
+[[Holdings]]
+Symbol = "MSFT"
+Account = "Schwab"
+Shares = 5.0
+Cost = 1500.0
+`, quotes)
+
+	var out []string
+	for i := 0; i < 2; i++ {
+		var b strings.Builder
+		var err error
+		h.do(func() { err = h.luc.Import(strings.NewReader(activity), ImportOptions{Watch: true}, &b) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, b.String())
+	}
+
+	var got []string
+	for _, hold := range h.luc.conf.Holdings {
+		got = append(got, fmt.Sprintf("%s %s %g %g", hold.Account, hold.Symbol, hold.Shares, hold.Cost))
+	}
+	if want := "Schwab MSFT 3 900, Schwab NOPE 1 10, Schwab SCHD 20 1530"; strings.Join(got, ", ") != want {
+		t.Errorf("got %s\nwant %s", strings.Join(got, ", "), want)
+	}
+	if !strings.Contains(out[0], "Watchlist: SCHD\n") || !strings.Contains(out[0], "Not added to the watchlist: NOPE: unknown symbol") {
+		t.Errorf("first import:\n%s", out[0])
+	}
+	if !strings.Contains(out[1], "transactions left out: 4, imported before") || !strings.Contains(out[1], "No changes to the holdings") {
+		t.Errorf("second import:\n%s", out[1])
+	}
+	if symbols := strings.Join(h.luc.getSymbols(), " "); symbols != "MSFT SCHD" {
+		t.Errorf("watchlist %s", symbols)
+	}
+}
@@ -0,0 +1,177 @@
+package lucrum
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/anorb/lucrum/pkg/brokers"
+	"github.com/anorb/lucrum/pkg/format"
+)
+
+// ImportOptions choose how Import reads a broker export.
+type ImportOptions struct {
+	// A built-in broker or a profile from [ImportProfiles] in the config,
+	// detected from the file when empty
+	Broker string
+	// Account of the rows of exports without an account column, the
+	// broker's name when empty
+	Account string
+	// Add the imported symbols to the watchlist
+	Watch bool
+	// Only show what would change
+	DryRun bool
+}
+
+// Import merges a broker export into the holdings and writes what changed
+// to w. The config is saved unless it's a dry run, but the holdings are
+// updated either way so several files can be previewed together.
+func (luc *Lucrum) Import(r io.Reader, opts ImportOptions, w io.Writer) error {
+	s, err := luc.parseExport(r, opts.Broker)
+	if err != nil {
+		return err
+	}
+	account := opts.Account
+	if account == "" {
+		account = s.Broker
+	}
+
+	imported := make(map[string]bool)
+	for _, id := range luc.conf.Imported {
+		imported[id] = true
+	}
+	old := luc.conf.Holdings
+	holdings, notes := mergeHoldings(old, imported, s, account)
+	luc.setHoldings(holdings)
+	luc.conf.Imported = nil
+	for id := range imported {
+		luc.conf.Imported = append(luc.conf.Imported, id)
+	}
+	sort.Strings(luc.conf.Imported)
+
+	fmt.Fprintf(w, "%s: %d positions, %d transactions\n", s.Broker, len(s.Positions), len(s.Transactions))
+	for _, skipped := range s.Skipped {
+		fmt.Fprintf(w, "  skipped %s\n", skipped)
+	}
+	for _, note := range notes {
+		fmt.Fprintf(w, "  %s\n", note)
+	}
+	writeHoldingChanges(w, old, holdings)
+	if opts.Watch {
+		luc.watchHoldings(w, holdings)
+	}
+
+	if opts.DryRun {
+		fmt.Fprintln(w, "Dry run, nothing saved")
+		return nil
+	}
+	return luc.saveConfig()
+}
+
+// watchHoldings adds the symbols of the holdings missing from the watchlist,
+// once the provider has confirmed them.
+func (luc *Lucrum) watchHoldings(w io.Writer, holdings []holding) {
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, h := range holdings {
+		if !luc.symbolExists(h.Symbol) && !seen[h.Symbol] {
+			candidates = append(candidates, h.Symbol)
+			seen[h.Symbol] = true
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	stocks, unknown, err := luc.validateSymbols(candidates)
+	if err != nil {
+		fmt.Fprintf(w, "Watchlist: could not check symbols: %s\n", err)
+		return
+	}
+	var added, rejected []string
+	for _, s := range stocks {
+		luc.stocks = append(luc.stocks, s)
+		added = append(added, s.Symbol)
+	}
+	for _, sym := range candidates {
+		if reason, ok := unknown[sym]; ok {
+			rejected = append(rejected, sym+": "+reason)
+		}
+	}
+	if len(added) > 0 {
+		fmt.Fprintf(w, "Watchlist: %s\n", strings.Join(added, " "))
+	}
+	if len(rejected) > 0 {
+		fmt.Fprintf(w, "Not added to the watchlist: %s\n", strings.Join(rejected, "; "))
+	}
+}
+
+func (luc *Lucrum) parseExport(r io.Reader, broker string) (brokers.Statement, error) {
+	if broker == "" {
+		return brokers.Detect(r)
+	}
+	if b, ok := brokers.Brokers[strings.ToLower(broker)]; ok {
+		return brokers.Parse(r, b)
+	}
+	if m, ok := luc.conf.ImportProfiles[broker]; ok {
+		return brokers.Parse(r, brokers.Custom(broker, m))
+	}
+
+	names := brokers.Names()
+	for name := range luc.conf.ImportProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names[len(brokers.Names()):])
+	return brokers.Statement{}, errors.New("Unknown broker " + broker + ", try " + strings.Join(names, ", "))
+}
+
+// writeHoldingChanges lists the holdings that are new, changed or gone.
+func writeHoldingChanges(w io.Writer, old, holdings []holding) {
+	before := make(map[holdingKey]holding)
+	for _, h := range old {
+		before[h.key()] = h
+	}
+	after := make(map[holdingKey]bool)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tSYMBOL\tSHARES\tCOST\t")
+	changes := 0
+	for _, h := range holdings {
+		after[h.key()] = true
+		b, existed := before[h.key()]
+		switch {
+		case !existed:
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\tnew\n", h.Account, h.Symbol, shares(h.Shares), cost(h.Cost))
+		case b.Shares != h.Shares || b.Cost != h.Cost:
+			fmt.Fprintf(tw, "%s\t%s\t%s → %s\t%s → %s\t\n", h.Account, h.Symbol, shares(b.Shares), shares(h.Shares), cost(b.Cost), cost(h.Cost))
+		default:
+			continue
+		}
+		changes++
+	}
+	for _, h := range old {
+		if !after[h.key()] {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\tgone\n", h.Account, h.Symbol, shares(h.Shares), cost(h.Cost))
+			changes++
+		}
+	}
+	if changes == 0 {
+		fmt.Fprintln(w, "No changes to the holdings")
+		return
+	}
+	tw.Flush()
+}
+
+func shares(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func cost(v float64) string {
+	if v == 0 {
+		return "-"
+	}
+	return format.Cash(v, 2)
+}
@@ -0,0 +1,92 @@
+// Package apierror describes failed requests to the quote providers, the
+// same way for each of them.
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/anorb/lucrum/pkg/fetch"
+)
+
+const snippetLength = 200
+
+var (
+	ErrRateLimited  = errors.New("rate limited")
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrUnavailable  = errors.New("upstream unavailable")
+	ErrMalformed    = errors.New("malformed payload")
+)
+
+// Error describes a failed request. It matches one of the Err values above
+// with errors.Is and carries the response details for errors.As.
+type Error struct {
+	Kind       error
+	StatusCode int
+	Snippet    string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	msg := e.Kind.Error()
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s (HTTP %d)", msg, e.StatusCode)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Snippet != "" {
+		msg += ": " + e.Snippet
+	}
+	return msg
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Get requests link with fetch.Get and turns transport failures and error
+// statuses into an *Error.
+func Get(link string, policy fetch.Policy) (fetch.Response, error) {
+	resp, err := fetch.Get(link, policy)
+	if errors.Is(err, fetch.ErrRateLimited) {
+		return resp, &Error{Kind: ErrRateLimited, Err: err}
+	} else if err != nil {
+		return resp, &Error{Kind: ErrUnavailable, StatusCode: resp.StatusCode, Err: err}
+	}
+	return resp, Status(resp.StatusCode, resp.Body)
+}
+
+// Status returns the error of a response's status code, nil for 2xx.
+func Status(statusCode int, body []byte) error {
+	kind := ErrUnavailable
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return nil
+	case statusCode == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case statusCode == http.StatusNotFound:
+		kind = ErrNotFound
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		kind = ErrUnauthorized
+	}
+	return &Error{Kind: kind, StatusCode: statusCode, Snippet: snippet(body)}
+}
+
+// Malformed is the error of a response body that couldn't be read.
+func Malformed(resp fetch.Response, err error) error {
+	return &Error{Kind: ErrMalformed, StatusCode: resp.StatusCode, Err: err, Snippet: snippet(resp.Body)}
+}
+
+func snippet(body []byte) string {
+	if len(body) > snippetLength {
+		return string(body[:snippetLength]) + "..."
+	}
+	return string(body)
+}
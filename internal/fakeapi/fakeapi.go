@@ -0,0 +1,129 @@
+// Package fakeapi is a stand-in for the Yahoo and CoinGecko APIs in tests.
+// It answers requests with canned responses, usually fixture files
+// recorded from the real APIs.
+package fakeapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Response is the answer to one request.
+type Response struct {
+	// 200 when zero
+	Status int
+	// The body, or the name of a file in the test's testdata directory
+	Body    string
+	Fixture string
+	Header  map[string]string
+	// Send only the first half of the body, then hang up
+	Truncate bool
+}
+
+// OK serves a fixture with status 200.
+func OK(fixture string) Response {
+	return Response{Fixture: fixture}
+}
+
+// Status answers with an empty body.
+func Status(code int) Response {
+	return Response{Status: code}
+}
+
+// RateLimited answers 429 asking the client to wait retryAfter seconds.
+func RateLimited(retryAfter int) Response {
+	return Response{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": strconv.Itoa(retryAfter)}}
+}
+
+// Server serves Responses by request path.
+type Server struct {
+	*httptest.Server
+
+	t        testing.TB
+	mutex    sync.Mutex
+	handlers map[string]func(r *http.Request) Response
+	requests []*url.URL
+}
+
+// New starts a server that is closed when the test ends.
+func New(t testing.TB) *Server {
+	s := &Server{t: t, handlers: make(map[string]func(r *http.Request) Response)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Handle answers requests for path with responses in turn, repeating the
+// last one once they run out.
+func (s *Server) Handle(path string, responses ...Response) {
+	var mutex sync.Mutex
+	next := 0
+	s.HandleFunc(path, func(r *http.Request) Response {
+		mutex.Lock()
+		defer mutex.Unlock()
+		resp := responses[next]
+		if next < len(responses)-1 {
+			next++
+		}
+		return resp
+	})
+}
+
+// HandleFunc answers requests for path with whatever f returns, e.g. to
+// answer depending on the query.
+func (s *Server) HandleFunc(path string, f func(r *http.Request) Response) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlers[path] = f
+}
+
+// Requests returns the URLs requested so far.
+func (s *Server) Requests() []*url.URL {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*url.URL(nil), s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests = append(s.requests, r.URL)
+	handler, ok := s.handlers[r.URL.Path]
+	s.mutex.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp := handler(r)
+	body := []byte(resp.Body)
+	if resp.Fixture != "" {
+		var err error
+		body, err = ioutil.ReadFile(filepath.Join("testdata", resp.Fixture))
+		if err != nil {
+			s.t.Errorf("fakeapi: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	for k, v := range resp.Header {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Truncate {
+		// Promise the whole body but send half, so the client's read fails
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		body = body[:len(body)/2]
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
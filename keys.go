@@ -0,0 +1,202 @@
+package lucrum
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cbind"
+	"gitlab.com/tslocum/cview"
+)
+
+// action is something the user can do from the watchlist. Every action has
+// a default key which can be remapped in the [Keys] section of the config,
+// e.g. refresh = "Ctrl+R". Several keys are separated by spaces.
+type action struct {
+	name        string
+	description string
+	key         string
+	handler     func()
+}
+
+func (luc *Lucrum) actions() []action {
+	return []action{
+		{"command", "Open the command line", ":", func() { luc.commandLine("") }},
+		{"filter", "Filter rows, e.g. apple, chg<-2 or exchange:NMS", "/", luc.filterPrompt},
+		{"refresh", "Refresh quotes", "u", luc.refreshAction},
+		{"add", "Add symbols", "a", luc.addPrompt},
+		{"remove", "Remove symbols", "r", luc.removePrompt},
+		{"delete", "Delete the selected row", "x", luc.deleteSelected},
+		{"move-up", "Move the selected row up", "K", func() { luc.moveSelected(-1) }},
+		{"move-down", "Move the selected row down", "J", func() { luc.moveSelected(1) }},
+		{"move-to", "Move the selected row to a position", "m", luc.movePrompt},
+		{"undo", "Undo the last delete", "U", luc.undoDelete},
+		{"group", "Insert a group separator", "n", luc.groupPrompt},
+		{"export", "Export the table to a file", "e", luc.exportPrompt},
+		{"chart", "Chart the selected symbol", "c", luc.chartSelected},
+		{"details", "Show details of the selected symbol", "Enter", func() { luc.showDetails(luc.selectedSymbol()) }},
+		{"dashboard", "Switch to the market overview", "d", luc.toggleDashboard},
+		{"leaderboard", "Show coins by market cap", "l", luc.showLeaderboard},
+		{"income", "Show the dividend income calendar", "D", luc.showIncome},
+		{"budget", "Show the request budget", "b", luc.showBudget},
+		{"help", "Show this help", "?", luc.showHelp},
+		{"quit", "Clear the filter, or quit", "Escape", luc.quitAction},
+	}
+}
+
+// initKeys binds every action to its configured or default keys.
+func (luc *Lucrum) initKeys() error {
+	actions := luc.actions()
+	known := make(map[string]bool)
+	for _, a := range actions {
+		known[a.name] = true
+	}
+	for name := range luc.conf.Keys {
+		if !known[name] {
+			return errors.New("Unknown action in keys: " + name)
+		}
+	}
+
+	type boundKey struct {
+		mod tcell.ModMask
+		key tcell.Key
+		ch  rune
+	}
+	bound := make(map[boundKey]string)
+
+	bindings := cbind.NewConfiguration()
+	luc.bindings = nil
+	for _, a := range actions {
+		keys := a.key
+		if k, ok := luc.conf.Keys[a.name]; ok {
+			keys = k
+		}
+		handler := a.handler
+		for _, key := range strings.Fields(keys) {
+			mod, k, ch, err := cbind.Decode(key)
+			if err != nil {
+				return fmt.Errorf("Invalid key %q for %s: %s", key, a.name, err)
+			}
+			if k != tcell.KeyRune {
+				ch = 0
+			}
+			if other, ok := bound[boundKey{mod, k, ch}]; ok {
+				return fmt.Errorf("Key %q is bound to both %s and %s, change one in [Keys]", key, other, a.name)
+			}
+			bound[boundKey{mod, k, ch}] = a.name
+			capture := func(ev *tcell.EventKey) *tcell.EventKey {
+				handler()
+				return nil
+			}
+			if k == tcell.KeyRune {
+				bindings.SetRune(mod, ch, capture)
+			} else {
+				bindings.SetKey(mod, k, capture)
+			}
+		}
+		a.key = keys
+		luc.bindings = append(luc.bindings, a)
+	}
+
+	luc.stockTable.SetSelectionChangedFunc(func(row, column int) {
+		sym := luc.selectedSymbol()
+		if reason, ok := luc.flags[sym]; ok {
+			luc.setStatus("%s: %s", sym, reason)
+		} else {
+			luc.setStatus("%s", luc.flagSummary())
+		}
+	})
+	luc.stockTable.SetInputCapture(bindings.Capture)
+	return nil
+}
+
+func (luc *Lucrum) quitAction() {
+	if luc.filterText != "" {
+		luc.setFilter("")
+		return
+	}
+	luc.cviewApp.Stop()
+}
+
+func (luc *Lucrum) refreshAction() {
+	luc.refresh()
+}
+
+func (luc *Lucrum) addPrompt() {
+	luc.commandLine("add ")
+}
+
+func (luc *Lucrum) removePrompt() {
+	luc.commandLine("rm ")
+}
+
+func (luc *Lucrum) movePrompt() {
+	luc.prompt("Move to position: ", func(text string) {
+		pos, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil {
+			luc.setStatus("Not a position: %s", text)
+			return
+		}
+		luc.moveSelectedTo(pos - 1)
+	})
+}
+
+func (luc *Lucrum) groupPrompt() {
+	luc.prompt("Group: ", func(text string) {
+		luc.insertGroup(text)
+	})
+}
+
+func (luc *Lucrum) chartSelected() {
+	if sym := luc.selectedSymbol(); sym != "" {
+		luc.showChart(sym)
+	}
+}
+
+// keyOf returns the first key bound to the action, for hints in messages,
+// or "" if it's unbound.
+func (luc *Lucrum) keyOf(name string) string {
+	for _, a := range luc.bindings {
+		if a.name == name {
+			if keys := strings.Fields(a.key); len(keys) > 0 {
+				return keys[0]
+			}
+		}
+	}
+	return ""
+}
+
+func (luc *Lucrum) helpText() string {
+	var b strings.Builder
+	for _, a := range luc.bindings {
+		fmt.Fprintf(&b, "%-16s %s\n", strings.Join(strings.Fields(a.key), ", "), a.description)
+	}
+	b.WriteString("\nCommands\n")
+	for _, name := range luc.commandNames() {
+		c := luc.commands[name]
+		fmt.Fprintf(&b, ":%s\n    %s\n", c.usage, c.description)
+	}
+	return b.String()
+}
+
+func (luc *Lucrum) showHelp() {
+	view := cview.NewTextView().SetText(luc.helpText())
+	view.SetBorder(true).SetTitle(" Keys ")
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' || event.Rune() == '?' {
+			luc.pages.RemovePage("help")
+			luc.cviewApp.SetFocus(luc.stockTable)
+			return nil
+		}
+		return event
+	})
+
+	// Centre the help over the watchlist
+	width, height := 60, strings.Count(luc.helpText(), "\n")+2
+	overlay := cview.NewGrid().SetColumns(0, width, 0).SetRows(0, height, 0).
+		AddItem(view, 1, 1, 1, 1, 0, 0, true)
+	luc.pages.AddPage("help", overlay, true, true)
+	luc.cviewApp.SetFocus(view)
+}
@@ -0,0 +1,46 @@
+package lucrum
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestKeyConflicts(t *testing.T) {
+	tests := []struct {
+		keys string
+		err  string
+	}{
+		{`refresh = "Ctrl+R"`, ""},
+		// Taking a key from another action means giving that one a new key
+		{`refresh = "r"
+remove = "R"`, ""},
+		{`refresh = "r"`, `Key "r" is bound to both refresh and remove, change one in [Keys]`},
+		{`chart = "Ctrl+R"
+export = "Ctrl+R"`, `Key "Ctrl+R" is bound to both export and chart, change one in [Keys]`},
+		{`nope = "z"`, "Unknown action in keys: nope"},
+	}
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "lucrum")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		conf := filepath.Join(dir, "conf")
+		if err := ioutil.WriteFile(conf, []byte("Symbols = []\n[Keys]\n"+test.keys+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = New(Options{ConfigPath: conf, CacheDir: dir, Screen: tcell.NewSimulationScreen("UTF-8"), Quotes: newFakeQuotes()})
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("%s: got error %q, want %q", test.keys, got, test.err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package lucrum
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anorb/lucrum/pkg/coingecko"
+	"github.com/anorb/lucrum/pkg/format"
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+const (
+	leaderboardPage = 50
+
+	// How close to the last row the selection gets before the next page loads
+	leaderboardPrefetch = 10
+)
+
+type leaderboardColumn struct {
+	header string
+	value  func(luc *Lucrum, c coingecko.MarketsResponse) string
+	change func(c coingecko.MarketsResponse) float64
+}
+
+func percentColumn(header string, change func(c coingecko.MarketsResponse) float64) leaderboardColumn {
+	return leaderboardColumn{header, func(luc *Lucrum, c coingecko.MarketsResponse) string {
+		return format.Percentage(change(c))
+	}, change}
+}
+
+func supply(v float64) string {
+	if v == 0 {
+		return "-"
+	}
+	return format.Abbreviate(v)
+}
+
+var leaderboardColumns = []leaderboardColumn{
+	{"#", func(luc *Lucrum, c coingecko.MarketsResponse) string {
+		// CoinGecko has no rank for some coins
+		if c.MarketCapRank == 0 {
+			return ""
+		}
+		return strconv.FormatInt(c.MarketCapRank, 10)
+	}, nil},
+	{"Symbol", func(luc *Lucrum, c coingecko.MarketsResponse) string { return strings.ToUpper(c.Symbol) }, nil},
+	{"Name", func(luc *Lucrum, c coingecko.MarketsResponse) string { return c.Name }, nil},
+	{"Price", func(luc *Lucrum, c coingecko.MarketsResponse) string {
+		return format.Money(c.CurrentPrice, format.Precision(c.CurrentPrice, 2), luc.currency())
+	}, nil},
+	percentColumn("1h", func(c coingecko.MarketsResponse) float64 { return c.PriceChangePercentage1HInCurrency }),
+	percentColumn("24h", func(c coingecko.MarketsResponse) float64 { return c.PriceChangePercentage24HInCurrency }),
+	percentColumn("7d", func(c coingecko.MarketsResponse) float64 { return c.PriceChangePercentage7DInCurrency }),
+	percentColumn("14d", func(c coingecko.MarketsResponse) float64 { return c.PriceChangePercentage14DInCurrency }),
+	percentColumn("30d", func(c coingecko.MarketsResponse) float64 { return c.PriceChangePercentage30DInCurrency }),
+	percentColumn("200d", func(c coingecko.MarketsResponse) float64 { return c.PriceChangePercentage200DInCurrency }),
+	percentColumn("1y", func(c coingecko.MarketsResponse) float64 { return c.PriceChangePercentage1YInCurrency }),
+	{"Mkt Cap", func(luc *Lucrum, c coingecko.MarketsResponse) string {
+		return format.AbbreviateMoney(float64(c.MarketCap), luc.currency())
+	}, nil},
+	{"Volume", func(luc *Lucrum, c coingecko.MarketsResponse) string {
+		return format.AbbreviateMoney(c.TotalVolume, luc.currency())
+	}, nil},
+	{"Supply", func(luc *Lucrum, c coingecko.MarketsResponse) string { return supply(c.CirculatingSupply) }, nil},
+	{"Max Supply", func(luc *Lucrum, c coingecko.MarketsResponse) string { return supply(c.MaxSupply) }, nil},
+}
+
+// leaderboard lists coins by market cap, loading a page at a time as the
+// selection nears the bottom.
+type leaderboard struct {
+	table   *cview.Table
+	coins   []coingecko.MarketsResponse
+	pages   int
+	loading bool
+	done    bool
+}
+
+func (luc *Lucrum) showLeaderboard() {
+	lb := &leaderboard{
+		table: cview.NewTable().SetBorders(false).SetSelectable(true, false).SetFixed(1, 2),
+	}
+	lb.table.SetBorder(true).SetTitle(" Crypto market cap ")
+	for i, col := range leaderboardColumns {
+		align := cview.AlignRight
+		if col.header == "Name" || col.header == "Symbol" {
+			align = cview.AlignLeft
+		}
+		lb.table.SetCell(0, i, cview.NewTableCell(col.header).
+			SetAlign(align).
+			SetAttributes(tcell.AttrBold).
+			SetTextColor(luc.theme.header).
+			SetSelectable(false))
+	}
+
+	lb.table.SetSelectionChangedFunc(func(row, column int) {
+		if row >= len(lb.coins)-leaderboardPrefetch {
+			luc.loadLeaderboardPage(lb)
+		}
+	})
+	lb.table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape || event.Rune() == 'q':
+			luc.pages.RemovePage("leaderboard")
+			luc.cviewApp.SetFocus(luc.stockTable)
+		case event.Key() == tcell.KeyEnter:
+			row, _ := lb.table.GetSelection()
+			if row > 0 && row <= len(lb.coins) {
+				luc.showCoin(lb.coins[row-1].ID)
+			}
+		case event.Rune() == 'a':
+			row, _ := lb.table.GetSelection()
+			if row > 0 && row <= len(lb.coins) {
+				// Yahoo quotes coins as e.g. BTC-USD or BTC-EUR
+				luc.addSymbols([]string{strings.ToUpper(lb.coins[row-1].Symbol + "-" + luc.currency())})
+			}
+		default:
+			return event
+		}
+		return nil
+	})
+
+	luc.pages.AddPage("leaderboard", lb.table, true, true)
+	luc.cviewApp.SetFocus(lb.table)
+	luc.loadLeaderboardPage(lb)
+}
+
+// loadLeaderboardPage fetches the next page of coins in the background.
+func (luc *Lucrum) loadLeaderboardPage(lb *leaderboard) {
+	if lb.loading || lb.done {
+		return
+	}
+	lb.loading = true
+	page := lb.pages + 1
+	lb.table.SetTitle(" Crypto market cap (loading) ")
+
+	currency := luc.currency()
+	go func() {
+		coins, err := coingecko.FetchMarkets(coingecko.MarketsOptions{
+			VsCurrency: currency,
+			PerPage:    leaderboardPage,
+			Page:       page,
+		})
+		luc.cviewApp.QueueUpdateDraw(func() {
+			lb.loading = false
+			lb.table.SetTitle(" Crypto market cap ")
+			if err != nil {
+				luc.setStatus("Leaderboard update failed: %s", err)
+				return
+			}
+			if len(coins) < leaderboardPage {
+				lb.done = true
+			}
+			lb.pages = page
+			lb.coins = byRank(append(lb.coins, coins...))
+			for i, c := range lb.coins {
+				luc.setLeaderboardRow(lb.table, i+1, c)
+			}
+		})
+	}()
+}
+
+// byRank sorts coins by market cap rank, keeping unranked coins last in
+// the order they came, even as later pages bring more ranked ones.
+func byRank(coins []coingecko.MarketsResponse) []coingecko.MarketsResponse {
+	sort.SliceStable(coins, func(i, j int) bool {
+		a, b := coins[i].MarketCapRank, coins[j].MarketCapRank
+		return a != 0 && (b == 0 || a < b)
+	})
+	return coins
+}
+
+func (luc *Lucrum) setLeaderboardRow(table *cview.Table, row int, c coingecko.MarketsResponse) {
+	for i, col := range leaderboardColumns {
+		color := luc.theme.neutral
+		text := col.value(luc, c)
+		align := cview.AlignRight
+		if col.header == "Name" || col.header == "Symbol" {
+			align = cview.AlignLeft
+		}
+		if col.change != nil {
+			change := col.change(c)
+			if change > 0 {
+				color = luc.theme.up
+			} else if change < 0 {
+				color = luc.theme.down
+			}
+			text = luc.glyph(change) + text
+		}
+		table.SetCell(row, i, cview.NewTableCell(text).SetAlign(align).SetTextColor(color))
+	}
+}
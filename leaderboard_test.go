@@ -0,0 +1,24 @@
+package lucrum
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anorb/lucrum/pkg/coingecko"
+)
+
+func TestLeaderboardRanks(t *testing.T) {
+	coin := func(symbol string, rank int64) coingecko.MarketsResponse {
+		return coingecko.MarketsResponse{Symbol: symbol, MarketCapRank: rank}
+	}
+	// The second page brings a ranked coin after an unranked one
+	coins := byRank([]coingecko.MarketsResponse{coin("btc", 1), coin("new", 0), coin("eth", 2)})
+	coins = byRank(append(coins, coin("old", 0), coin("sol", 3)))
+	var got []string
+	for _, c := range coins {
+		got = append(got, c.Symbol+":"+leaderboardColumns[0].value(nil, c))
+	}
+	if want := "btc:1 eth:2 sol:3 new: old:"; strings.Join(got, " ") != want {
+		t.Errorf("got %s, want %s", strings.Join(got, " "), want)
+	}
+}
@@ -1,69 +1,256 @@
 package lucrum
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/anorb/lucrum/pkg/brokers"
+	"github.com/anorb/lucrum/pkg/coingecko"
+	"github.com/anorb/lucrum/pkg/fetch"
+	"github.com/anorb/lucrum/pkg/indicators"
 	"github.com/anorb/lucrum/pkg/yahoofinance"
 	"github.com/gdamore/tcell"
 	"gitlab.com/tslocum/cview"
 )
 
+const rateLimitBackoff = time.Minute
+
+const (
+	defaultInterval = 5 * time.Second
+	minInterval     = time.Second
+)
+
 type Lucrum struct {
+	pages          *cview.Pages
 	grid           *cview.Grid
 	stockTable     *cview.Table
+	status         *cview.TextView
 	stockMutex     *sync.Mutex
 	stocks         []yahoofinance.Stock
 	cviewApp       *cview.Application
 	updateInterval time.Duration
+	refreshing     bool
 	lastUpdate     time.Time
 	configPath     string
+	cacheDir       string
+	conf           config
+
+	quotes QuoteSource
+	now    func() time.Time
+
+	columns          []column
+	usesDaily        bool
+	usesSession      bool
+	candles          map[string][]indicators.Candle
+	sessions         map[string][]indicators.Candle
+	lastCandleUpdate time.Time
+
+	usesDividends     bool
+	lastDividendCheck time.Time
+
+	fetchingColumnData bool
+
+	suggest *suggester
+	coins   *coingecko.Resolver
+	flags   map[string]string
+
+	rendered map[string]renderedRow
+	ticks    map[string]int
+	flashes  map[flashCell]time.Time
+	theme    theme
+	bindings []action
+
+	view       []int
+	sortColumn string
+	sortDesc   bool
+	listType   string
+	filter     []filterTerm
+	filterText string
+	message    string
+
+	commands map[string]command
+	history  []string
+	alerts   []alert
+
+	dashboard *dashboard
+	deleted   *deletedRow
+
+	dividends  map[string]dividends
+	held       map[string]holding
+	heldQuotes map[string]yahoofinance.Stock
+
+	// When the quotes shown were fetched, and whether they came from the
+	// cache of the last session or there's no network at all
+	quotesTime time.Time
+	cached     bool
+	offline    bool
 }
 
 type config struct {
-	Symbols []string
+	Symbols  []string
+	Columns  []string `toml:",omitempty"`
+	Overlays []string `toml:",omitempty"`
+
+	// Requests per minute keyed by provider, "yahoo" or "coingecko"
+	RateLimits map[string]float64 `toml:",omitempty"`
+
+	Theme *themeConfig      `toml:"theme,omitempty"`
+	Keys  map[string]string `toml:",omitempty"`
+
+	Interval string   `toml:",omitempty"`
+	Alerts   []string `toml:",omitempty"`
+
+	// CoinGecko IDs picked for tickers several coins share, e.g. UNI
+	CoinIDs map[string]string `toml:",omitempty"`
+
+	// Currency of CoinGecko prices, e.g. "eur"
+	Currency string `toml:",omitempty"`
+
+	// Open on the market overview instead of the watchlist
+	Dashboard bool `toml:",omitempty"`
+
+	Holdings []holding `toml:",omitempty"`
+	// Buys and sells already applied to the holdings, so importing an
+	// activity export again doesn't count them twice
+	Imported []string `toml:",omitempty"`
+	// Column mappings for exports of brokers lucrum doesn't know, by name
+	ImportProfiles map[string]brokers.Mapping `toml:",omitempty"`
 }
 
+// Init sets up a normal session, panicking if the config can't be loaded.
 func Init() *Lucrum {
+	luc, err := New(Options{})
+	if err != nil {
+		panic(err)
+	}
+	return luc
+}
+
+// New sets up lucrum with anything in opts in place of the terminal,
+// network and clock.
+func New(opts Options) (*Lucrum, error) {
 	luc := &Lucrum{}
-	luc.configPath = "conf"
+	luc.configPath = opts.ConfigPath
+	if luc.configPath == "" {
+		luc.configPath = "conf"
+	}
+	luc.cacheDir = opts.CacheDir
+	if luc.cacheDir == "" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			luc.cacheDir = filepath.Join(dir, "lucrum")
+		}
+	}
+	luc.quotes = opts.Quotes
+	if luc.quotes == nil {
+		luc.quotes = yahooSource{}
+	}
+	luc.now = opts.Now
+	if luc.now == nil {
+		luc.now = time.Now
+	}
+	luc.candles = make(map[string][]indicators.Candle)
+	luc.sessions = make(map[string][]indicators.Candle)
+	luc.dividends = make(map[string]dividends)
+	luc.heldQuotes = make(map[string]yahoofinance.Stock)
+	luc.suggest = newSuggester()
+	coinPath := ""
+	if luc.cacheDir != "" {
+		coinPath = filepath.Join(luc.cacheDir, "coinlist.json")
+	}
+	luc.coins = coingecko.NewResolver(coinPath)
+	luc.flags = make(map[string]string)
+	luc.ticks = make(map[string]int)
+	luc.flashes = make(map[flashCell]time.Time)
 
 	if _, err := os.Stat(luc.configPath); err == nil {
-		err := luc.loadConfig()
-		if err != nil {
-			panic(err)
+		if err := luc.loadConfig(); err != nil {
+			return nil, err
 		}
 	} else if os.IsNotExist(err) {
 		luc.stocks = append(luc.stocks, yahoofinance.Stock{Symbol: "ORCL"}, yahoofinance.Stock{Symbol: "AAPL"}, yahoofinance.Stock{Symbol: "IBM"})
 	}
 
+	luc.applyRateLimits()
+	theme, err := loadTheme(luc.conf.Theme)
+	if err != nil {
+		return nil, err
+	}
+	luc.theme = theme
+
 	cview.Styles.PrimitiveBackgroundColor = tcell.ColorDefault
 	cview.Styles.PrimaryTextColor = tcell.ColorDefault
 	luc.cviewApp = cview.NewApplication()
-	luc.stockTable = cview.NewTable().SetBorders(false)
-	luc.grid = cview.NewGrid().AddItem(luc.stockTable, 0, 0, 1, 1, 0, 0, true)
-	luc.updateInterval = 5
+	if opts.Screen != nil {
+		// cview only initialises screens it makes itself
+		if err := opts.Screen.Init(); err != nil {
+			return nil, err
+		}
+		opts.Screen.EnableMouse()
+		luc.cviewApp.SetScreen(opts.Screen)
+	}
+	luc.stockTable = cview.NewTable().SetBorders(false).SetSelectable(true, false).SetFixed(1, 0)
+	luc.status = cview.NewTextView().SetDynamicColors(false)
+	luc.grid = cview.NewGrid().SetRows(0, 1, 1).
+		AddItem(luc.stockTable, 0, 0, 1, 1, 0, 0, true).
+		AddItem(luc.status, 1, 0, 1, 1, 0, 0, false)
+	luc.pages = cview.NewPages().AddPage("main", luc.grid, true, true)
+	luc.applyTheme()
+	luc.updateInterval = defaultInterval
+	if luc.conf.Interval != "" {
+		d, err := time.ParseDuration(luc.conf.Interval)
+		if err != nil {
+			return nil, err
+		}
+		// Shorter intervals would refresh on every tick
+		if d >= minInterval {
+			luc.updateInterval = d
+		}
+	}
 	luc.stockMutex = new(sync.Mutex)
 
-	headerLabels := []string{"Symbol", fmt.Sprintf("%15s", "Current"), "Change", "Change%", "High", "Low", "Open"}
-	for key, val := range headerLabels {
-		luc.stockTable.SetCell(0, key, cview.NewTableCell(val).
+	if err := luc.initColumns(luc.conf.Columns); err != nil {
+		return nil, err
+	}
+	for key, col := range luc.columns {
+		luc.stockTable.SetCell(0, key, cview.NewTableCell(col.header).
 			SetAlign(cview.AlignRight).
-			SetAttributes(tcell.AttrBold))
+			SetAttributes(tcell.AttrBold).
+			SetTextColor(luc.theme.header).
+			SetSelectable(false))
 	}
 
-	luc.initKeys()
-	luc.refresh()
+	if err := luc.loadAlerts(); err != nil {
+		return nil, err
+	}
+	luc.initCommands()
+	luc.loadHistory()
+	if err := luc.initKeys(); err != nil {
+		return nil, err
+	}
+	luc.initMouse()
+	luc.initDashboard()
 
-	return luc
+	// Show the last session's quotes straight away, UpdateLoop refreshes
+	// them in the background
+	luc.loadQuotes()
+	luc.updateStockRows()
+	if luc.conf.Dashboard {
+		luc.toggleDashboard()
+	}
+
+	return luc, nil
 }
 
 func (luc *Lucrum) Run() {
-	if err := luc.cviewApp.SetRoot(luc.grid, true).EnableMouse(true).Run(); err != nil {
+	err := luc.cviewApp.SetRoot(luc.pages, true).EnableMouse(true).Run()
+	luc.saveQuotes()
+	if err != nil {
 		panic(err)
 	}
 }
@@ -73,109 +260,143 @@ func (luc *Lucrum) UpdateLoop() {
 	for {
 		select {
 		case <-updateTicker.C:
-			luc.cviewApp.QueueUpdateDraw(func() {
-				if time.Now().Unix()-luc.lastUpdate.Unix() >= int64(luc.updateInterval) {
-					luc.refresh()
-				}
-			})
+			luc.cviewApp.QueueUpdateDraw(luc.tick)
 		}
 	}
 }
 
-func (luc *Lucrum) initKeys() {
-	luc.stockTable.SetDoneFunc(func(key tcell.Key) {
-		if key == tcell.KeyEscape {
-			luc.cviewApp.Stop()
+// tick runs every second, refreshing quotes once they're due.
+func (luc *Lucrum) tick() {
+	luc.clearFlashes()
+	if luc.now().Sub(luc.lastUpdate) >= luc.updateInterval {
+		if luc.dashboard.visible {
+			luc.refreshDashboard()
 		}
-	})
+		luc.refresh()
+	}
+}
 
-	luc.stockTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Rune() == 'u' {
-			luc.refresh()
+// prompt shows a one line input below the table and calls done with its
+// text when Enter is pressed.
+func (luc *Lucrum) prompt(label string, done func(text string)) *cview.InputField {
+	input := cview.NewInputField().SetLabel(label).SetFieldWidth(100)
+	input.SetFieldBackgroundColor(tcell.ColorDefault)
+	input.SetFieldTextColor(tcell.ColorDefault)
+	input.SetLabelColor(tcell.ColorDefault)
+	input.SetPlaceholderTextColor(tcell.ColorDefault)
+
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			done(input.GetText())
 		}
-		if event.Rune() == 'a' {
-			input := cview.NewInputField().SetLabel("Add: ").SetFieldWidth(100)
-			input.SetFieldBackgroundColor(tcell.ColorDefault)
-			input.SetFieldTextColor(tcell.ColorDefault)
-			input.SetLabelColor(tcell.ColorDefault)
-			input.SetPlaceholderTextColor(tcell.ColorDefault)
-
-			input.SetDoneFunc(func(key tcell.Key) {
-				if key == tcell.KeyEnter {
-					text := input.GetText()
-					luc.addSymbols(strings.Split(text, " "))
-				}
-			})
-
-			input.SetFinishedFunc(func(key tcell.Key) {
-				luc.grid.RemoveItem(input)
-				luc.cviewApp.SetFocus(luc.stockTable)
-			})
-
-			luc.grid.AddItem(input, 2, 0, 1, 1, 0, 0, false)
-			luc.cviewApp.SetFocus(input)
-		}
-		if event.Rune() == 'r' {
-			input := cview.NewInputField().SetLabel("Remove: ").SetFieldWidth(100)
-			input.SetFieldBackgroundColor(tcell.ColorDefault)
-			input.SetFieldTextColor(tcell.ColorDefault)
-			input.SetLabelColor(tcell.ColorDefault)
-			input.SetPlaceholderTextColor(tcell.ColorDefault)
-
-			input.SetDoneFunc(func(key tcell.Key) {
-				if key == tcell.KeyEnter {
-					text := input.GetText()
-					luc.removeSymbols(strings.Split(text, " "))
-				}
-			})
-
-			input.SetFinishedFunc(func(key tcell.Key) {
-				luc.grid.RemoveItem(input)
-				luc.cviewApp.SetFocus(luc.stockTable)
-			})
-
-			luc.grid.AddItem(input, 2, 0, 1, 1, 0, 0, false)
-			luc.cviewApp.SetFocus(input)
-		}
-		return event
 	})
+
+	input.SetFinishedFunc(func(key tcell.Key) {
+		luc.grid.RemoveItem(input)
+		luc.cviewApp.SetFocus(luc.stockTable)
+	})
+
+	luc.grid.AddItem(input, 2, 0, 1, 1, 0, 0, false)
+	luc.cviewApp.SetFocus(input)
+	return input
 }
 
+// refresh fetches quotes in the background and updates the table once they
+// arrive. Only one refresh runs at a time.
 func (luc *Lucrum) refresh() {
-	luc.stockMutex.Lock()
-	defer luc.stockMutex.Unlock()
-	luc.updateStocks()
-	luc.updateStockRows()
+	if luc.refreshing {
+		return
+	}
+	luc.refreshing = true
+	luc.lastUpdate = luc.now()
+	symbols := luc.quoteSymbols()
+
+	go func() {
+		stocks, err := luc.quotes.FetchQuote(symbols)
+		luc.cviewApp.QueueUpdateDraw(func() {
+			luc.refreshing = false
+			luc.stockMutex.Lock()
+			defer luc.stockMutex.Unlock()
+			luc.updateStocks(stocks, err)
+			luc.updateStockRows()
+			// Charts are slower to fetch, so they follow the quotes
+			if err == nil {
+				luc.refreshColumnData(symbols)
+			}
+		})
+	}()
 }
 
-func (luc *Lucrum) updateStocks() {
-	var err error
+func (luc *Lucrum) updateStocks(stocks []yahoofinance.Stock, err error) {
+	// A failed batch keeps its stale quotes while the rest update
+	var batch *fetch.BatchError
+	failed := make(map[string]bool)
+	if errors.As(err, &batch) && len(stocks) > 0 {
+		for _, sym := range batch.Failed() {
+			failed[sym] = true
+		}
+	} else if err != nil {
+		luc.lastUpdate = luc.now()
+		if errors.Is(err, yahoofinance.ErrRateLimited) {
+			// Push the next update back instead of hammering the API
+			luc.lastUpdate = luc.lastUpdate.Add(rateLimitBackoff)
+			luc.setStatus("Rate limited by Yahoo, retrying in %s", rateLimitBackoff)
+			return
+		}
+		// Without a single fetch this session, assume there's no network
+		if errors.Is(err, yahoofinance.ErrUnavailable) && (luc.cached || luc.offline || luc.quotesTime.IsZero()) {
+			luc.goOffline(err)
+			return
+		}
+		luc.setStatus("Update failed: %s", err)
+		return
+	}
 
-	luc.stocks, err = yahoofinance.FetchQuote(luc.getSymbols())
-	if err != nil {
-		panic(err)
+	// Keep the watchlist's own order and any symbol the provider dropped
+	previous := make(map[string]yahoofinance.Stock)
+	for _, s := range luc.stocks {
+		previous[s.Symbol] = s
+	}
+	luc.recordTicks(previous, stocks)
+	fetched := make(map[string]bool)
+	for _, s := range stocks {
+		previous[s.Symbol] = s
+		fetched[s.Symbol] = true
+	}
+	old := luc.stocks
+	luc.stocks = make([]yahoofinance.Stock, len(old))
+	for i, s := range old {
+		luc.stocks[i] = previous[s.Symbol]
+	}
+
+	luc.checkResolved(previous, fetched, failed)
+	luc.setStatus("%s", luc.flagSummary())
+	if batch != nil {
+		luc.setStatus("Update failed for %d symbols: %s", len(batch.Failed()), batch)
+	}
+	luc.checkAlerts()
+	luc.lastUpdate = luc.now()
+	luc.quotesTime = luc.lastUpdate
+	luc.cached = false
+	luc.offline = false
+	luc.saveQuotes()
+}
+
+func (luc *Lucrum) selectedSymbol() string {
+	i := luc.selectedIndex()
+	if i == -1 || isGroup(luc.stocks[i].Symbol) {
+		return ""
 	}
-	luc.lastUpdate = time.Now()
+	return luc.stocks[i].Symbol
 }
 
-func (luc *Lucrum) updateStockRows() {
-	rowOffset := 1
+func (luc *Lucrum) stock(symbol string) (yahoofinance.Stock, bool) {
 	for _, s := range luc.stocks {
-		rowColor := tcell.ColorDefault
-		if s.RegularMarketChange > 0 {
-			rowColor = tcell.ColorPaleGreen
-		} else if s.RegularMarketChange < 0 {
-			rowColor = tcell.ColorPaleVioletRed
+		if s.Symbol == symbol {
+			return s, true
 		}
-		luc.stockTable.SetCell(rowOffset, 0, generateCell(s.Symbol, cview.AlignRight, rowColor))
-		luc.stockTable.SetCell(rowOffset, 1, generateCell(s.FormattedRegularMarketPrice, cview.AlignRight, rowColor))
-		luc.stockTable.SetCell(rowOffset, 2, generateCell(s.FormattedRegularMarketChange, cview.AlignRight, rowColor))
-		luc.stockTable.SetCell(rowOffset, 3, generateCell(s.FormattedRegularMarketChangePct, cview.AlignRight, rowColor))
-		luc.stockTable.SetCell(rowOffset, 4, generateCell(s.FormattedRegularMarketDayHigh, cview.AlignRight, rowColor))
-		luc.stockTable.SetCell(rowOffset, 5, generateCell(s.FormattedRegularMarketDayLow, cview.AlignRight, rowColor))
-		luc.stockTable.SetCell(rowOffset, 6, generateCell(s.FormattedRegularMarketDayOpen, cview.AlignRight, rowColor))
-		rowOffset++
 	}
+	return yahoofinance.Stock{}, false
 }
 
 func (luc *Lucrum) symbolExists(s string) bool {
@@ -187,63 +408,91 @@ func (luc *Lucrum) symbolExists(s string) bool {
 	return false
 }
 
+// addSymbols checks the symbols with the provider in the background, so a
+// slow network doesn't hold up the UI, then adds the ones it knows.
 func (luc *Lucrum) addSymbols(s []string) {
+	if luc.readOnly() {
+		return
+	}
 	luc.stockMutex.Lock()
-	var toAdd []yahoofinance.Stock
+	var candidates []string
 	for _, sym := range s {
 		upperSym := strings.ToUpper(sym)
 		if !luc.symbolExists(upperSym) {
-			toAdd = append(toAdd, yahoofinance.Stock{Symbol: upperSym})
+			candidates = append(candidates, upperSym)
 		}
 	}
-	luc.stocks = append(luc.stocks, toAdd...)
-	err := luc.saveConfig()
+	luc.stockMutex.Unlock()
+	if len(candidates) == 0 {
+		return
+	}
+
+	luc.setStatus("Checking %s", strings.Join(candidates, " "))
+	go func() {
+		toAdd, unknown, err := luc.validateSymbols(candidates)
+		luc.cviewApp.QueueUpdateDraw(func() {
+			luc.addValidated(candidates, toAdd, unknown, err)
+		})
+	}()
+}
+
+func (luc *Lucrum) addValidated(candidates []string, toAdd []yahoofinance.Stock, unknown map[string]string, err error) {
 	if err != nil {
-		panic(err)
+		luc.setStatus("Could not check symbols: %s", err)
+		return
+	}
+	luc.stockMutex.Lock()
+	for _, s := range toAdd {
+		// Added again while this check ran
+		if !luc.symbolExists(s.Symbol) {
+			luc.stocks = append(luc.stocks, s)
+		}
 	}
+	err = luc.saveConfig()
 	luc.stockMutex.Unlock()
-	luc.refresh()
+	luc.updateStockRows()
+	luc.setStatus("")
+	if err != nil {
+		luc.setStatus("Could not save the config: %s", err)
+		return
+	}
+
+	var messages []string
+	for _, sym := range candidates {
+		if reason, ok := unknown[sym]; ok {
+			messages = append(messages, sym+": "+reason)
+		}
+	}
+	if len(messages) > 0 {
+		luc.setStatus("Not added: %s", strings.Join(messages, "; "))
+	}
 }
 
 func (luc *Lucrum) removeSymbols(s []string) {
+	if luc.readOnly() {
+		return
+	}
 	luc.stockMutex.Lock()
 	for _, sym := range s {
-		index := -1
-		sym = strings.ToUpper(sym)
-		for key, val := range luc.stocks {
-			if val.Symbol == sym {
-				index = key
-				break
-			}
-		}
-		if index != -1 {
+		if index := luc.watchlistIndex(sym); index != -1 {
 			luc.stocks = append(luc.stocks[:index], luc.stocks[index+1:]...)
-			err := luc.saveConfig()
-			if err != nil {
-				panic(err)
-			}
-			// Remove row from table
-			for i := 0; i < luc.stockTable.GetRowCount(); i++ {
-				// Get the cell containing the symbol
-				c := luc.stockTable.GetCell(i, 0)
-				if c.Text == sym {
-					luc.stockTable.RemoveRow(i)
-					break
-				}
-			}
 		}
 	}
+	err := luc.saveConfig()
 	luc.stockMutex.Unlock()
-	luc.refresh()
+	luc.updateStockRows()
+	if err != nil {
+		luc.setStatus("Could not save the config: %s", err)
+	}
 }
 
 func (luc *Lucrum) loadConfig() error {
 	path := luc.configPath
-	conf := &config{}
-	if _, err := toml.DecodeFile(path, &conf); err != nil {
+	if _, err := toml.DecodeFile(path, &luc.conf); err != nil {
 		return err
 	}
-	for _, sym := range conf.Symbols {
+	luc.setHoldings(luc.conf.Holdings)
+	for _, sym := range luc.conf.Symbols {
 		luc.stocks = append(luc.stocks, yahoofinance.Stock{Symbol: sym})
 	}
 	return nil
@@ -251,7 +500,7 @@ func (luc *Lucrum) loadConfig() error {
 
 func (luc *Lucrum) saveConfig() error {
 	path := luc.configPath
-	conf := &config{}
+	conf := &luc.conf
 
 	conf.Symbols = luc.getSymbols()
 	f, err := os.Create(path)
@@ -275,6 +524,34 @@ func (luc *Lucrum) getSymbols() []string {
 	return symbols
 }
 
+// currency is what CoinGecko prices are shown in, USD unless configured.
+func (luc *Lucrum) currency() string {
+	if luc.conf.Currency == "" {
+		return "usd"
+	}
+	return strings.ToLower(luc.conf.Currency)
+}
+
+func (luc *Lucrum) setStatus(format string, a ...interface{}) {
+	luc.message = fmt.Sprintf(format, a...)
+	luc.drawStatus()
+}
+
+// drawStatus shows the last status message, after the filter if one is set.
+func (luc *Lucrum) drawStatus() {
+	text := luc.message
+	if luc.filterText != "" {
+		shown := 0
+		for _, i := range luc.view {
+			if !isGroup(luc.stocks[i].Symbol) {
+				shown++
+			}
+		}
+		text = fmt.Sprintf("/%s (%d of %d)  %s", luc.filterText, shown, len(luc.quoteSymbols()), text)
+	}
+	luc.status.SetText(text)
+}
+
 func generateCell(content string, align int, background tcell.Color) *cview.TableCell {
 	return cview.NewTableCell(content).SetAlign(align).SetBackgroundColor(background)
 }
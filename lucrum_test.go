@@ -0,0 +1,577 @@
+package lucrum
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/fetch"
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+	"github.com/gdamore/tcell"
+)
+
+// fakeQuotes serves quotes from memory and counts the fetches.
+type fakeQuotes struct {
+	mutex  sync.Mutex
+	stocks map[string]yahoofinance.Stock
+	charts map[string]yahoofinance.Chart
+	err    error
+	// Symbols whose chunk fails, the rest are still quoted
+	broken map[string]bool
+	// Quote and chart fetches wait for it to close when set
+	gate  chan struct{}
+	calls int
+	// Chart requests made, as "SYMBOL range interval"
+	chartCalls []string
+}
+
+func newFakeQuotes(stocks ...yahoofinance.Stock) *fakeQuotes {
+	q := &fakeQuotes{stocks: make(map[string]yahoofinance.Stock), charts: make(map[string]yahoofinance.Chart), broken: make(map[string]bool)}
+	q.set(stocks...)
+	return q
+}
+
+func (q *fakeQuotes) set(stocks ...yahoofinance.Stock) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, s := range stocks {
+		q.stocks[s.Symbol] = s
+	}
+}
+
+func (q *fakeQuotes) fail(err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.err = err
+}
+
+func (q *fakeQuotes) fetches() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.calls
+}
+
+// hold makes quote fetches hang like a slow network until the returned
+// func is called.
+func (q *fakeQuotes) hold() func() {
+	gate := make(chan struct{})
+	q.mutex.Lock()
+	q.gate = gate
+	q.mutex.Unlock()
+	return func() {
+		q.mutex.Lock()
+		q.gate = nil
+		q.mutex.Unlock()
+		close(gate)
+	}
+}
+
+func (q *fakeQuotes) FetchQuote(symbols []string) ([]yahoofinance.Stock, error) {
+	q.mutex.Lock()
+	gate := q.gate
+	q.mutex.Unlock()
+	if gate != nil {
+		<-gate
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.calls++
+	if q.err != nil {
+		return nil, q.err
+	}
+	var stocks []yahoofinance.Stock
+	var failed []string
+	for _, sym := range symbols {
+		if q.broken[sym] {
+			failed = append(failed, sym)
+		} else if s, ok := q.stocks[sym]; ok {
+			stocks = append(stocks, s)
+		}
+	}
+	if len(failed) > 0 {
+		return stocks, &fetch.BatchError{Chunks: []fetch.ChunkError{{Items: failed, Err: yahoofinance.ErrUnavailable}}}
+	}
+	return stocks, nil
+}
+
+func (q *fakeQuotes) breakSymbols(symbols ...string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, sym := range symbols {
+		q.broken[sym] = true
+	}
+}
+
+func (q *fakeQuotes) setChart(symbol string, chart yahoofinance.Chart) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.charts[symbol] = chart
+}
+
+func (q *fakeQuotes) FetchChart(symbol, chartRange, interval string) (yahoofinance.Chart, error) {
+	q.mutex.Lock()
+	gate := q.gate
+	q.mutex.Unlock()
+	if gate != nil {
+		<-gate
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.chartCalls = append(q.chartCalls, symbol+" "+chartRange+" "+interval)
+	if q.err != nil {
+		return yahoofinance.Chart{}, q.err
+	}
+	return q.charts[symbol], nil
+}
+
+func (q *fakeQuotes) Search(query string) ([]yahoofinance.SearchResult, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.err != nil {
+		return nil, q.err
+	}
+	var results []yahoofinance.SearchResult
+	for sym := range q.stocks {
+		if strings.HasPrefix(sym, strings.ToUpper(query)) {
+			results = append(results, yahoofinance.SearchResult{Symbol: sym})
+		}
+	}
+	return results, nil
+}
+
+func quote(symbol string, price float64) yahoofinance.Stock {
+	s := yahoofinance.Stock{Symbol: symbol, RegularMarketPrice: price, QuoteType: "EQUITY"}
+	s.FormattedRegularMarketPrice = s.FormatPrice(price)
+	return s
+}
+
+// harness runs lucrum on a simulated screen with fake quotes and a clock
+// that only moves when the test says so.
+type harness struct {
+	t      *testing.T
+	dir    string
+	luc    *Lucrum
+	screen tcell.SimulationScreen
+	quotes *fakeQuotes
+
+	// Only touched on the event loop
+	now     time.Time
+	handled int
+	sent    int
+}
+
+func newHarness(t *testing.T, conf string, quotes *fakeQuotes) *harness {
+	dir, err := ioutil.TempDir("", "lucrum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &harness{t: t, dir: dir, quotes: quotes, now: time.Date(2024, 3, 4, 15, 0, 0, 0, time.UTC)}
+
+	// An empty coin list keeps suggestions off the network
+	cache := filepath.Join(dir, "cache")
+	if err := os.MkdirAll(cache, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(cache, "coinlist.json"), []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "conf"), []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h.screen = tcell.NewSimulationScreen("UTF-8")
+	h.luc, err = New(Options{
+		ConfigPath: filepath.Join(dir, "conf"),
+		CacheDir:   cache,
+		Screen:     h.screen,
+		Quotes:     quotes,
+		Now:        func() time.Time { return h.now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.screen.SetSize(120, 20)
+
+	// Count keys as they reach the event loop so press can wait for them
+	h.luc.cviewApp.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		h.handled++
+		return event
+	})
+
+	done := make(chan struct{})
+	go func() {
+		h.luc.Run()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		h.luc.cviewApp.Stop()
+		<-done
+		os.RemoveAll(dir)
+	})
+	// Stopping before Run has the screen would make cview open the terminal
+	h.do(func() {})
+	return h
+}
+
+// do runs f on the event loop, redraws and waits for it to finish.
+func (h *harness) do(f func()) {
+	done := make(chan struct{})
+	h.luc.cviewApp.QueueUpdate(func() {
+		f()
+		h.luc.cviewApp.ForceDraw()
+		close(done)
+	})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		h.t.Fatal("event loop stuck")
+	}
+}
+
+// waitFor polls cond on the event loop until it holds.
+func (h *harness) waitFor(what string, cond func() bool) {
+	h.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ok := false
+		h.do(func() { ok = cond() })
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %s, screen:\n%s", what, h.text())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (h *harness) key(k tcell.Key, r rune) {
+	h.t.Helper()
+	h.sent++
+	sent := h.sent
+	h.screen.InjectKey(k, r, tcell.ModNone)
+	h.waitFor("a key press", func() bool { return h.handled >= sent })
+}
+
+// press types text, e.g. "a" or "TSLA".
+func (h *harness) press(text string) {
+	h.t.Helper()
+	for _, r := range text {
+		h.key(tcell.KeyRune, r)
+	}
+}
+
+// tick advances the clock by d and runs UpdateLoop's once a second check.
+func (h *harness) tick(d time.Duration) {
+	h.t.Helper()
+	h.do(func() {
+		h.now = h.now.Add(d)
+		h.luc.tick()
+	})
+	h.waitFor("the refresh", func() bool { return !h.luc.refreshing })
+}
+
+// text returns what's on screen, one line per row.
+func (h *harness) text() string {
+	var b strings.Builder
+	h.do(func() {
+		cells, width, _ := h.screen.GetContents()
+		for i, c := range cells {
+			if len(c.Bytes) == 0 {
+				b.WriteByte(' ')
+			} else {
+				b.Write(c.Bytes)
+			}
+			if (i+1)%width == 0 {
+				b.WriteByte('\n')
+			}
+		}
+	})
+	return b.String()
+}
+
+// line returns the row starting with prefix, inside an overlay's border too.
+func (h *harness) line(prefix string) string {
+	for _, l := range strings.Split(h.text(), "\n") {
+		if strings.HasPrefix(strings.TrimLeft(l, " │║"), prefix) {
+			return l
+		}
+	}
+	return ""
+}
+
+func (h *harness) conf() string {
+	body, err := ioutil.ReadFile(filepath.Join(h.dir, "conf"))
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestStartupRefresh(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87), quote("MSFT", 402.5))
+	h := newHarness(t, `Symbols = ["AAPL", "MSFT"]`, quotes)
+
+	if got := h.line("AAPL"); got == "" || strings.Contains(got, "$172.87") {
+		t.Fatalf("before the first refresh got row %q", got)
+	}
+	h.tick(time.Second)
+	if !strings.Contains(h.line("AAPL"), "$172.87") || !strings.Contains(h.line("MSFT"), "$402.50") {
+		t.Errorf("quotes not shown:\n%s", h.text())
+	}
+
+	// Nothing is fetched until the interval passes
+	h.tick(2 * time.Second)
+	if n := quotes.fetches(); n != 1 {
+		t.Errorf("fetched %d times within the interval, want 1", n)
+	}
+	quotes.set(quote("AAPL", 175))
+	h.tick(5 * time.Second)
+	if n := quotes.fetches(); n != 2 {
+		t.Errorf("fetched %d times, want 2", n)
+	}
+	if !strings.Contains(h.line("AAPL"), "$175.00") {
+		t.Errorf("new quote not shown:\n%s", h.text())
+	}
+}
+
+func TestConfigInterval(t *testing.T) {
+	for conf, want := range map[string]time.Duration{`Interval = "30s"`: 30 * time.Second, `Interval = "0s"`: defaultInterval} {
+		h := newHarness(t, "Symbols = [\"AAPL\"]\n"+conf, newFakeQuotes(quote("AAPL", 172.87)))
+		if h.luc.updateInterval != want {
+			t.Errorf("%s: refreshing every %s, want %s", conf, h.luc.updateInterval, want)
+		}
+	}
+}
+
+func TestRefreshKey(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87))
+	h := newHarness(t, `Symbols = ["AAPL"]`, quotes)
+	h.tick(time.Second)
+
+	quotes.set(quote("AAPL", 180))
+	h.press("u")
+	h.waitFor("the new quote", func() bool { return !h.luc.refreshing && h.luc.stocks[0].RegularMarketPrice == 180 })
+	if !strings.Contains(h.line("AAPL"), "$180.00") {
+		t.Errorf("new quote not shown:\n%s", h.text())
+	}
+}
+
+func TestPartialFailure(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87), quote("MSFT", 402.5), quote("TSLA", 201.3))
+	h := newHarness(t, `Symbols = ["AAPL", "MSFT", "TSLA", "GONE"]`, quotes)
+	h.tick(time.Second)
+	if _, ok := h.luc.flags["GONE"]; !ok {
+		t.Fatal("GONE not flagged")
+	}
+
+	// GONE's chunk fails, so its flag stays and MSFT isn't flagged for it
+	quotes.breakSymbols("MSFT", "GONE")
+	quotes.set(quote("AAPL", 180))
+	h.tick(5 * time.Second)
+	if _, ok := h.luc.flags["GONE"]; !ok {
+		t.Error("flag of a failed symbol cleared")
+	}
+	if flag, ok := h.luc.flags["MSFT"]; ok {
+		t.Errorf("failed symbol flagged: %s", flag)
+	}
+	if !strings.Contains(h.luc.message, "Update failed for 2 symbols") {
+		t.Errorf("got status %q", h.luc.message)
+	}
+	if !strings.Contains(h.line("AAPL"), "$180.00") || !strings.Contains(h.line("MSFT"), "$402.50") {
+		t.Errorf("quotes not kept or updated:\n%s", h.text())
+	}
+}
+
+func TestChartInBackground(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87))
+	quotes.setChart("AAPL", yahoofinance.Chart{Candles: []yahoofinance.Candle{
+		{Open: 170, High: 174, Low: 169, Close: 172, Volume: 1000},
+		{Open: 172, High: 175, Low: 171, Close: 173, Volume: 1200},
+	}})
+	h := newHarness(t, `Symbols = ["AAPL"]`, quotes)
+	h.tick(time.Second)
+
+	// The table keeps taking keys while the candles load
+	release := quotes.hold()
+	h.press("c")
+	h.press("j")
+	if !strings.Contains(h.luc.message, "Loading the chart of AAPL") || h.luc.pages.HasPage("chart") {
+		t.Errorf("chart opened early, status %q", h.luc.message)
+	}
+	release()
+	h.waitFor("the chart", func() bool { return h.luc.pages.HasPage("chart") })
+}
+
+func TestReplacementSuggestion(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87), quote("FBA", 12))
+	h := newHarness(t, `Symbols = ["AAPL", "FB"]`, quotes)
+	h.tick(time.Second)
+	h.waitFor("the replacement", func() bool { return strings.Contains(h.luc.flags["FB"], "did you mean FBA") })
+	if !strings.HasPrefix(h.luc.flags["FB"], "no longer returned by Yahoo") {
+		t.Errorf("got flag %q", h.luc.flags["FB"])
+	}
+}
+
+func TestAddAndRemove(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87), quote("TSLA", 201.3))
+	h := newHarness(t, `Symbols = ["AAPL"]`, quotes)
+	h.tick(time.Second)
+
+	h.press("a")
+	if !strings.Contains(h.text(), ":add ") {
+		t.Fatalf("command line not opened:\n%s", h.text())
+	}
+	h.press("TSLA NOPE")
+	h.key(tcell.KeyEnter, 0)
+	h.waitFor("the check", func() bool { return strings.HasPrefix(h.luc.message, "Not added") })
+	if !strings.Contains(h.line("TSLA"), "$201.30") {
+		t.Errorf("TSLA not added:\n%s", h.text())
+	}
+	if !strings.Contains(h.text(), "Not added: NOPE: unknown symbol") {
+		t.Errorf("unknown symbol not reported:\n%s", h.text())
+	}
+	if !strings.Contains(h.conf(), `"AAPL", "TSLA"`) {
+		t.Errorf("config not saved:\n%s", h.conf())
+	}
+
+	h.press("r")
+	h.press("AAPL")
+	h.key(tcell.KeyEnter, 0)
+	if h.line("AAPL") != "" {
+		t.Errorf("AAPL not removed:\n%s", h.text())
+	}
+	if strings.Contains(h.conf(), "AAPL") {
+		t.Errorf("config not saved:\n%s", h.conf())
+	}
+}
+
+func TestAddSlowNetwork(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87), quote("TSLA", 201.3))
+	h := newHarness(t, `Symbols = ["AAPL"]`, quotes)
+	h.tick(time.Second)
+
+	release := quotes.hold()
+	h.press("a")
+	// The space keeps the suggestions closed
+	h.press("TSLA ")
+	h.key(tcell.KeyEnter, 0)
+	// Keys are still handled while the symbol is being checked
+	h.press("?")
+	if !strings.Contains(h.text(), "Add symbols") {
+		t.Errorf("UI not responding while checking:\n%s", h.text())
+	}
+	h.key(tcell.KeyEscape, 0)
+	release()
+	h.waitFor("TSLA", func() bool { _, ok := h.luc.stock("TSLA"); return ok })
+}
+
+func TestOffline(t *testing.T) {
+	quotes := newFakeQuotes()
+	quotes.fail(yahoofinance.ErrUnavailable)
+	h := newHarness(t, `Symbols = ["AAPL"]`, quotes)
+	h.tick(time.Second)
+
+	if !strings.Contains(h.text(), "Offline, no quotes cached (read-only)") {
+		t.Fatalf("not offline:\n%s", h.text())
+	}
+	h.press("x")
+	if h.line("AAPL") == "" || !strings.Contains(h.text(), "the watchlist is read-only") {
+		t.Errorf("deleted a row while offline:\n%s", h.text())
+	}
+
+	// The next successful refresh goes back online
+	quotes.fail(nil)
+	quotes.set(quote("AAPL", 172.87))
+	h.tick(5 * time.Second)
+	if h.luc.offline || !strings.Contains(h.line("AAPL"), "$172.87") {
+		t.Errorf("still offline:\n%s", h.text())
+	}
+}
+
+func TestDeleteAndGroups(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87), quote("MSFT", 402.5))
+	h := newHarness(t, `Symbols = ["AAPL", "# Big Tech", "MSFT"]`, quotes)
+	h.tick(time.Second)
+
+	h.press("x")
+	if h.line("AAPL") != "" || !strings.Contains(h.text(), "Deleted AAPL, press U to undo") {
+		t.Fatalf("AAPL not deleted:\n%s", h.text())
+	}
+	h.press("U")
+	if !strings.Contains(h.line("AAPL"), "$172.87") || !strings.Contains(h.conf(), `"AAPL", "# Big Tech", "MSFT"`) {
+		t.Errorf("AAPL not restored in place:\n%s\n%s", h.text(), h.conf())
+	}
+
+	// n inserts a group, leaving g to the table
+	h.press("n")
+	h.press("Cash")
+	h.key(tcell.KeyEnter, 0)
+	if !strings.Contains(h.conf(), `"# Cash", "AAPL"`) {
+		t.Errorf("group not inserted:\n%s", h.conf())
+	}
+
+	h.press(":rm # big tech")
+	h.key(tcell.KeyEnter, 0)
+	if strings.Contains(h.conf(), "Big Tech") {
+		t.Errorf("group not removed:\n%s", h.conf())
+	}
+}
+
+func TestEditsWithoutSaving(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87), quote("MSFT", 402.5))
+	h := newHarness(t, `Symbols = ["AAPL", "MSFT"]`, quotes)
+	h.tick(time.Second)
+	h.do(func() { h.luc.configPath = filepath.Join(h.dir, "missing", "conf") })
+
+	h.press("x")
+	if h.line("AAPL") != "" || !strings.Contains(h.luc.message, "Could not save the config") {
+		t.Errorf("delete not reported, status %q:\n%s", h.luc.message, h.text())
+	}
+	h.press("U")
+	if h.line("AAPL") == "" || !strings.Contains(h.luc.message, "Could not save the config") {
+		t.Errorf("undo not reported, status %q:\n%s", h.luc.message, h.text())
+	}
+	h.press(":rm MSFT")
+	h.key(tcell.KeyEnter, 0)
+	if h.line("MSFT") != "" || !strings.Contains(h.luc.message, "Could not save the config") {
+		t.Errorf("rm not reported, status %q:\n%s", h.luc.message, h.text())
+	}
+}
+
+func TestRenderKeyedBySymbol(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87), quote("MSFT", 402.5), quote("TSLA", 201.3))
+	h := newHarness(t, `Symbols = ["AAPL", "MSFT", "TSLA"]`, quotes)
+	h.tick(time.Second)
+
+	// Removing the first row moves the others up, which isn't a change
+	h.press("x")
+	var flashed int
+	h.do(func() { flashed = len(h.luc.flashes) })
+	if flashed != 0 {
+		t.Errorf("%d cells flashed for moving rows", flashed)
+	}
+
+	// A moved row still flashes when its quote ticks
+	quotes.set(quote("MSFT", 410))
+	h.tick(5 * time.Second)
+	h.do(func() {
+		r := h.luc.rendered["MSFT"]
+		if r.row != 1 {
+			t.Errorf("MSFT rendered at row %d, want 1", r.row)
+		}
+		if _, ok := h.luc.flashes[flashCell{"MSFT", 1}]; !ok {
+			t.Errorf("MSFT's price didn't flash, flashes %v", h.luc.flashes)
+		}
+		if _, ok := h.luc.flashes[flashCell{"TSLA", 1}]; ok {
+			t.Error("TSLA flashed without ticking")
+		}
+	})
+	if !strings.Contains(h.line("MSFT"), "$410.00") || h.line("AAPL") != "" {
+		t.Errorf("rows not rendered:\n%s", h.text())
+	}
+}
@@ -0,0 +1,135 @@
+package lucrum
+
+import (
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+// initMouse handles clicks on the watchlist. The table itself selects rows
+// and scrolls with the wheel; this adds sorting by header, details on
+// double-click and a context menu on right-click.
+func (luc *Lucrum) initMouse() {
+	luc.stockTable.SetMouseCapture(func(action cview.MouseAction, event *tcell.EventMouse) (cview.MouseAction, *tcell.EventMouse) {
+		x, y := event.Position()
+		if !luc.stockTable.InRect(x, y) {
+			return action, event
+		}
+		row := luc.rowAt(y)
+
+		switch action {
+		case cview.MouseLeftClick:
+			if row == 0 {
+				luc.sortByHeader(x)
+				return action, nil
+			}
+			// Don't let the table select past the last row
+			if row < 0 || row > len(luc.view) {
+				return action, nil
+			}
+		case cview.MouseLeftDoubleClick:
+			if row > 0 && row <= len(luc.view) {
+				luc.showDetails(luc.selectedSymbol())
+			}
+			return action, nil
+		case cview.MouseRightClick:
+			if row > 0 && row <= len(luc.view) {
+				luc.stockTable.Select(row, 0)
+				luc.showMenu(x, y)
+			}
+			return action, nil
+		}
+		return action, event
+	})
+}
+
+// rowAt returns the table row at screen line y, or -1 if there is none.
+func (luc *Lucrum) rowAt(y int) int {
+	_, top, _, _ := luc.stockTable.GetInnerRect()
+	row := y - top
+	if row >= 1 {
+		offset, _ := luc.stockTable.GetOffset()
+		row += offset
+	}
+	if row < 0 || row >= luc.stockTable.GetRowCount() {
+		return -1
+	}
+	return row
+}
+
+// sortByHeader sorts by the column whose header is at x. Clicking the
+// sorted column again reverses the order, and a third time unsorts it.
+func (luc *Lucrum) sortByHeader(x int) {
+	for i, col := range luc.columns {
+		cellX, _, width := luc.stockTable.GetCell(0, i).GetLastPosition()
+		if x < cellX || x >= cellX+width+1 {
+			continue
+		}
+		switch {
+		case luc.sortColumn != col.name():
+			luc.sortColumn, luc.sortDesc = col.name(), false
+		case !luc.sortDesc:
+			luc.sortDesc = true
+		default:
+			luc.sortColumn = ""
+		}
+		luc.updateStockRows()
+		return
+	}
+}
+
+// showMenu opens the actions for the selected row at x, y.
+func (luc *Lucrum) showMenu(x, y int) {
+	sym := luc.selectedSymbol()
+	if sym == "" {
+		return
+	}
+
+	closeMenu := func() {
+		luc.pages.RemovePage("menu")
+		luc.cviewApp.SetFocus(luc.stockTable)
+	}
+	menu := cview.NewList().ShowSecondaryText(false)
+	menu.SetBorder(true).SetTitle(" " + sym + " ")
+	menu.AddItem("Chart", "", 'c', func() {
+		closeMenu()
+		luc.showChart(sym)
+	})
+	menu.AddItem("Details", "", 'd', func() {
+		closeMenu()
+		luc.showDetails(sym)
+	})
+	menu.AddItem("Alert", "", 'a', func() {
+		closeMenu()
+		luc.commandLine("alert " + sym + " > ")
+	})
+	menu.AddItem("Move to", "", 'm', func() {
+		closeMenu()
+		luc.movePrompt()
+	})
+	menu.AddItem("Remove", "", 'r', func() {
+		closeMenu()
+		luc.removeSymbols([]string{sym})
+	})
+	menu.SetDoneFunc(closeMenu)
+
+	// Clicking anywhere else dismisses the menu
+	menu.SetMouseCapture(func(action cview.MouseAction, event *tcell.EventMouse) (cview.MouseAction, *tcell.EventMouse) {
+		if (action == cview.MouseLeftClick || action == cview.MouseRightClick) && !menu.InRect(event.Position()) {
+			closeMenu()
+			return action, nil
+		}
+		return action, event
+	})
+
+	width, height := 20, menu.GetItemCount()+2
+	_, _, screenWidth, screenHeight := luc.grid.GetRect()
+	if x+width > screenWidth {
+		x = screenWidth - width
+	}
+	if y+height > screenHeight {
+		y = screenHeight - height
+	}
+	menu.SetRect(x, y, width, height)
+	luc.pages.AddPage("menu", menu, false, true)
+	luc.cviewApp.SetFocus(menu)
+}
@@ -0,0 +1,189 @@
+package lucrum
+
+import (
+	"strings"
+
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+)
+
+// Group separators are kept in the watchlist alongside the symbols, written
+// as "# Name", so they are saved in order with everything else.
+const groupPrefix = "# "
+
+func isGroup(sym string) bool {
+	return strings.HasPrefix(sym, "#")
+}
+
+func groupName(sym string) string {
+	return strings.TrimSpace(strings.TrimPrefix(sym, "#"))
+}
+
+// quoteSymbols returns the watchlist's symbols without group separators.
+func (luc *Lucrum) quoteSymbols() []string {
+	var symbols []string
+	for _, sym := range luc.getSymbols() {
+		if !isGroup(sym) {
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols
+}
+
+func (luc *Lucrum) selectedIndex() int {
+	row, _ := luc.stockTable.GetSelection()
+	if row < 1 || row > len(luc.view) {
+		return -1
+	}
+	return luc.view[row-1]
+}
+
+func (luc *Lucrum) moveSelected(offset int) {
+	if luc.reordered() {
+		luc.setStatus("Clear the sort and list filter to move rows")
+		return
+	}
+	if i := luc.selectedIndex(); i != -1 {
+		luc.moveSelectedTo(i + offset)
+	}
+}
+
+// moveSelectedTo moves the selected row to index to, clamped to the list.
+func (luc *Lucrum) moveSelectedTo(to int) {
+	if luc.readOnly() {
+		return
+	}
+	luc.stockMutex.Lock()
+	defer luc.stockMutex.Unlock()
+
+	from := luc.selectedIndex()
+	if from == -1 {
+		return
+	}
+	if luc.reordered() {
+		luc.setStatus("Clear the sort and list filter to move rows")
+		return
+	}
+	if to < 0 {
+		to = 0
+	}
+	if to >= len(luc.stocks) {
+		to = len(luc.stocks) - 1
+	}
+	if from == to {
+		return
+	}
+
+	s := luc.stocks[from]
+	luc.stocks = append(luc.stocks[:from], luc.stocks[from+1:]...)
+	luc.stocks = append(luc.stocks[:to], append([]yahoofinance.Stock{s}, luc.stocks[to:]...)...)
+	err := luc.saveConfig()
+	luc.updateStockRows()
+	luc.stockTable.Select(luc.rowOf(to), 0)
+	if err != nil {
+		luc.setStatus("Could not save the config: %s", err)
+	}
+}
+
+// insertGroup adds a group separator above the selected row.
+func (luc *Lucrum) insertGroup(name string) {
+	name = strings.TrimSpace(name)
+	if name == "" || luc.readOnly() {
+		return
+	}
+	luc.stockMutex.Lock()
+	defer luc.stockMutex.Unlock()
+
+	at := luc.selectedIndex()
+	if at == -1 {
+		at = len(luc.stocks)
+	}
+	group := yahoofinance.Stock{Symbol: groupPrefix + name}
+	luc.stocks = append(luc.stocks[:at], append([]yahoofinance.Stock{group}, luc.stocks[at:]...)...)
+	err := luc.saveConfig()
+	luc.updateStockRows()
+	if row := luc.rowOf(at); row != -1 {
+		luc.stockTable.Select(row, 0)
+	}
+	if err != nil {
+		luc.setStatus("Could not save the config: %s", err)
+	}
+}
+
+// deletedRow is the row last deleted with x, kept so it can be undone.
+type deletedRow struct {
+	index int
+	stock yahoofinance.Stock
+}
+
+func (luc *Lucrum) deleteSelected() {
+	if luc.readOnly() {
+		return
+	}
+	luc.stockMutex.Lock()
+	defer luc.stockMutex.Unlock()
+
+	i := luc.selectedIndex()
+	if i == -1 {
+		return
+	}
+	s := luc.stocks[i]
+	luc.stocks = append(luc.stocks[:i], luc.stocks[i+1:]...)
+	luc.deleted = &deletedRow{i, s}
+	err := luc.saveConfig()
+	luc.updateStockRows()
+	if err != nil {
+		luc.setStatus("Could not save the config: %s", err)
+	} else if key := luc.keyOf("undo"); key != "" {
+		luc.setStatus("Deleted %s, press %s to undo", s.Symbol, key)
+	}
+}
+
+// undoDelete puts the last deleted row back where it was.
+func (luc *Lucrum) undoDelete() {
+	if luc.deleted == nil {
+		luc.setStatus("Nothing to undo")
+		return
+	}
+	if luc.readOnly() {
+		return
+	}
+	luc.stockMutex.Lock()
+	defer luc.stockMutex.Unlock()
+
+	d := *luc.deleted
+	luc.deleted = nil
+	if luc.symbolExists(d.stock.Symbol) {
+		luc.setStatus("%s is back already", d.stock.Symbol)
+		return
+	}
+	at := d.index
+	if at > len(luc.stocks) {
+		at = len(luc.stocks)
+	}
+	luc.stocks = append(luc.stocks[:at], append([]yahoofinance.Stock{d.stock}, luc.stocks[at:]...)...)
+	err := luc.saveConfig()
+	luc.updateStockRows()
+	if row := luc.rowOf(at); row != -1 {
+		luc.stockTable.Select(row, 0)
+	}
+	if err != nil {
+		luc.setStatus("Could not save the config: %s", err)
+		return
+	}
+	luc.setStatus("Restored %s", d.stock.Symbol)
+}
+
+// watchlistIndex finds a symbol, ignoring case, or a group given as
+// "# Name" by its name, which keeps its case in the watchlist.
+func (luc *Lucrum) watchlistIndex(sym string) int {
+	for i, s := range luc.stocks {
+		if isGroup(sym) {
+			if isGroup(s.Symbol) && strings.EqualFold(groupName(s.Symbol), groupName(sym)) {
+				return i
+			}
+		} else if s.Symbol == strings.ToUpper(sym) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,165 @@
+// Package brokers reads the position and transaction CSVs brokers let
+// customers download.
+package brokers
+
+import (
+	"strings"
+	"time"
+)
+
+// Position is a holding in a positions export.
+type Position struct {
+	Account  string
+	Symbol   string
+	Quantity float64
+	// Total cost basis, zero if the export doesn't have it
+	CostBasis float64
+}
+
+// Action is what a transaction did.
+type Action int
+
+const (
+	Other Action = iota
+	Buy
+	Sell
+	Dividend
+)
+
+func (a Action) String() string {
+	switch a {
+	case Buy:
+		return "buy"
+	case Sell:
+		return "sell"
+	case Dividend:
+		return "dividend"
+	}
+	return "other"
+}
+
+// Transaction is a line of an activity export.
+type Transaction struct {
+	Account  string
+	Date     time.Time
+	Action   Action
+	Symbol   string
+	Quantity float64
+	Price    float64
+	// Cash paid or received, negative for money going out
+	Amount float64
+}
+
+// Mapping names the columns of a table in an export, so exports from
+// brokers without a built-in format can be read too. Several names for one
+// column are separated by "|". Tables with Date and Action columns are
+// transactions, others are positions.
+type Mapping struct {
+	Account   string `toml:",omitempty"`
+	Symbol    string `toml:",omitempty"`
+	Quantity  string `toml:",omitempty"`
+	CostBasis string `toml:",omitempty"`
+
+	Date   string `toml:",omitempty"`
+	Action string `toml:",omitempty"`
+	Price  string `toml:",omitempty"`
+	Amount string `toml:",omitempty"`
+	// Layouts of Date in Go's format, e.g. "02.01.2006", separated by "|".
+	// Common US and ISO layouts are tried when empty.
+	DateFormat string `toml:",omitempty"`
+
+	// Only rows whose column has the value are read, e.g. an asset class
+	Only map[string]string `toml:",omitempty"`
+	// Separator of the columns, a comma when empty
+	Delimiter string `toml:",omitempty"`
+}
+
+func (m Mapping) transactions() bool {
+	return m.Date != "" && (m.Action != "" || m.Amount != "")
+}
+
+// Broker is an export format made of one or more tables.
+type Broker struct {
+	Name   string
+	Tables []Mapping
+	// Separator of the columns, a comma when zero
+	Comma rune
+	// IBKR writes every table into one file, each row prefixed with the
+	// table's name and whether it's a header or data row.
+	sectioned bool
+	// US brokers write class shares as BRK.B, which elsewhere would be an
+	// exchange suffix like VOD.L
+	classDots bool
+}
+
+// Brokers are the built-in formats, keyed by the name given on the command
+// line.
+var Brokers = map[string]Broker{
+	"fidelity": {Name: "Fidelity", classDots: true, Tables: []Mapping{
+		{Account: "Account Name|Account Number", Symbol: "Symbol", Quantity: "Quantity", CostBasis: "Cost Basis Total"},
+		{Account: "Account|Account Name", Date: "Run Date", Action: "Action", Symbol: "Symbol", Quantity: "Quantity", Price: "Price ($)|Price", Amount: "Amount ($)|Amount"},
+	}},
+	"schwab": {Name: "Schwab", classDots: true, Tables: []Mapping{
+		{Symbol: "Symbol", Quantity: "Quantity|Qty (Quantity)", CostBasis: "Cost Basis"},
+		{Date: "Date", Action: "Action", Symbol: "Symbol", Quantity: "Quantity", Price: "Price", Amount: "Amount"},
+	}},
+	"vanguard": {Name: "Vanguard", classDots: true, Tables: []Mapping{
+		{Account: "Account Number", Symbol: "Symbol", Quantity: "Shares"},
+		{Account: "Account Number", Date: "Trade Date", Action: "Transaction Type", Symbol: "Symbol", Quantity: "Shares", Price: "Share Price", Amount: "Net Amount|Principal Amount"},
+	}},
+	"ibkr": {Name: "Interactive Brokers", sectioned: true, Tables: []Mapping{
+		{Symbol: "Open Positions/Symbol", Quantity: "Open Positions/Quantity", CostBasis: "Open Positions/Cost Basis",
+			Only: map[string]string{"Open Positions/Asset Category": "Stocks", "Open Positions/DataDiscriminator": "Summary"}},
+		{Date: "Trades/Date/Time", Amount: "Trades/Proceeds", Symbol: "Trades/Symbol", Quantity: "Trades/Quantity", Price: "Trades/T. Price",
+			Only: map[string]string{"Trades/Asset Category": "Stocks", "Trades/DataDiscriminator": "Order"}},
+		{Date: "Dividends/Date", Amount: "Dividends/Amount", Symbol: "Dividends/Description", Action: "Dividends/Description"},
+	}},
+}
+
+// Names returns the built-in brokers' names, in the order Detect tries them.
+// IBKR goes first, otherwise its positions pass for Schwab's.
+func Names() []string {
+	return []string{"ibkr", "fidelity", "schwab", "vanguard"}
+}
+
+// Custom makes a Broker of a Mapping, e.g. one from the config.
+func Custom(name string, m Mapping) Broker {
+	b := Broker{Name: name, Tables: []Mapping{m}}
+	if m.Delimiter != "" {
+		b.Comma = []rune(m.Delimiter)[0]
+	}
+	return b
+}
+
+func (b Broker) comma() rune {
+	if b.Comma == 0 {
+		return ','
+	}
+	return b.Comma
+}
+
+// action works out what a transaction did from the broker's description,
+// falling back on the sign of the quantity.
+func action(description string, quantity float64) Action {
+	d := strings.ToUpper(description)
+	switch {
+	case strings.Contains(d, "REINVEST"):
+		// Reinvested shares are bought, the dividend paying for them is
+		// usually a separate line without a quantity
+		if quantity != 0 {
+			return Buy
+		}
+		return Dividend
+	case strings.Contains(d, "DIVIDEND"):
+		return Dividend
+	case strings.Contains(d, "BOUGHT") || strings.Contains(d, "BUY") || strings.Contains(d, "PURCHASE"):
+		return Buy
+	case strings.Contains(d, "SOLD") || strings.Contains(d, "SELL"):
+		return Sell
+	case description == "" && quantity > 0:
+		return Buy
+	case description == "" && quantity < 0:
+		return Sell
+	}
+	return Other
+}
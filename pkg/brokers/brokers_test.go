@@ -0,0 +1,165 @@
+package brokers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func open(t *testing.T, name string) *os.File {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func positions(s Statement) string {
+	var out []string
+	for _, p := range s.Positions {
+		out = append(out, fmt.Sprintf("%s %s %g %g", p.Account, p.Symbol, p.Quantity, p.CostBasis))
+	}
+	return strings.Join(out, "\n")
+}
+
+func transactions(s Statement) string {
+	var out []string
+	for _, t := range s.Transactions {
+		out = append(out, fmt.Sprintf("%s %s %s %g %g %g", t.Date.Format("2006-01-02"), t.Action, t.Symbol, t.Quantity, t.Price, t.Amount))
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		file         string
+		broker       string
+		positions    string
+		transactions string
+		skipped      int
+	}{
+		{
+			file:   "fidelity_positions.csv",
+			broker: "Fidelity",
+			positions: "Individual AAPL 50 7500\n" +
+				"Individual BRK-B 10.5 3600\n" +
+				"ROTH IRA VTI 1200 240000",
+			skipped: 2,
+		},
+		{
+			file:   "fidelity_activity.csv",
+			broker: "Fidelity",
+			transactions: "2024-03-01 buy AAPL 10 179.66 -1796.6\n" +
+				"2024-02-15 dividend AAPL 0 0 12\n" +
+				"2024-02-10 sell MSFT 5 410 2049.98",
+		},
+		{
+			file:      "schwab_positions.csv",
+			broker:    "Schwab",
+			positions: " MSFT 20 6000\n SCHD 100.25 7100",
+			skipped:   2,
+		},
+		{
+			file:   "schwab_transactions.csv",
+			broker: "Schwab",
+			transactions: "2024-03-01 buy SCHD 10 76.5 -765\n" +
+				"2024-02-28 dividend SCHD 0 0 61.2\n" +
+				"2024-02-28 buy SCHD 0.8 76.5 -61.2\n" +
+				"2024-02-20 sell MSFT 2 405 809.95",
+		},
+		{
+			file:      "vanguard.csv",
+			broker:    "Vanguard",
+			positions: "12345678 VTI 40.5 0\n12345678 VMFXX 1000 0\n12345678 O 30 0",
+			transactions: "2024-02-15 dividend O 0 1 7.71\n" +
+				"2024-02-02 buy VTI 5 245 -1225",
+			skipped: 1,
+		},
+		{
+			file:      "ibkr.csv",
+			broker:    "Interactive Brokers",
+			positions: " AAPL 30 4815\n BRK-B 4 1400",
+			transactions: "2024-01-05 buy AAPL 10 181.2 -1812\n" +
+				"2024-02-20 sell MSFT 3 405 1215\n" +
+				"2024-02-15 dividend AAPL 0 0 7.2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			s, err := Detect(open(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Broker != tt.broker {
+				t.Errorf("detected %s, want %s", s.Broker, tt.broker)
+			}
+			if got := positions(s); got != tt.positions {
+				t.Errorf("positions\n%s\nwant\n%s", got, tt.positions)
+			}
+			if got := transactions(s); got != tt.transactions {
+				t.Errorf("transactions\n%s\nwant\n%s", got, tt.transactions)
+			}
+			if len(s.Skipped) != tt.skipped {
+				t.Errorf("skipped %q, want %d rows", s.Skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestCustom(t *testing.T) {
+	m := Mapping{Account: "Portefeuille", Symbol: "Titre", Quantity: "Nombre", CostBasis: "PRU total", Delimiter: ";"}
+	s, err := Parse(open(t, "custom.csv"), Custom("mybank", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := positions(s), "PEA MC.PA 5 3500\nPEA AI.PA 12 1800.5"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := Detect(open(t, "custom.csv")); err == nil {
+		t.Error("detected a custom format")
+	}
+	if _, err := Parse(open(t, "custom.csv"), Brokers["schwab"]); err == nil {
+		t.Error("read a custom format as Schwab's")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"aapl":                    "AAPL",
+		"BRK.B":                   "BRK.B",
+		"BRK/B":                   "BRK-B",
+		"BRK B":                   "BRK-B",
+		"VOD.L":                   "VOD.L",
+		"FXAIX*":                  "FXAIX",
+		"AAPL(US0378331005) Cash": "AAPL",
+		"SPAXX**":                 "",
+		"Cash & Cash Investments": "",
+		"Account Total":           "",
+		"AAPL 15MAR24 180 C":      "",
+	}
+	for in, want := range tests {
+		got, err := normalize(in, false)
+		if got != want || (want == "") != (err != nil) {
+			t.Errorf("normalize(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if got, _ := normalize("BRK.B", true); got != "BRK-B" {
+		t.Errorf("normalize(BRK.B) with class dots = %q, want BRK-B", got)
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := map[string]float64{"$1,234.56": 1234.56, "(12.50)": -12.5, "-$5": -5, "+0.72": 0.72, "--": 0, "": 0}
+	for in, want := range tests {
+		if got, err := number(in); err != nil || got != want {
+			t.Errorf("number(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	if _, err := number("abc"); err == nil {
+		t.Error("read abc as a number")
+	}
+}
@@ -0,0 +1,322 @@
+package brokers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date layouts tried when a Mapping has no DateFormat
+var dateLayouts = []string{"01/02/2006", "2006-01-02", "2006/01/02", "2006-01-02, 15:04:05", "2006-01-02 15:04:05", "1/2/2006"}
+
+var (
+	validSymbol   = regexp.MustCompile(`^[A-Z0-9^][A-Z0-9.\-=^]*$`)
+	shareClass    = regexp.MustCompile(`^([A-Z]+)[ /]([A-Z])$`)
+	shareClassDot = regexp.MustCompile(`^([A-Z]+)\.([A-Z])$`)
+)
+
+// Statement is what was read from an export.
+type Statement struct {
+	Broker       string
+	Positions    []Position
+	Transactions []Transaction
+	// Rows that couldn't be read, with the reason
+	Skipped []string
+}
+
+// Parse reads an export in b's format.
+func Parse(r io.Reader, b Broker) (Statement, error) {
+	rows, err := readRows(r, b.comma())
+	if err != nil {
+		return Statement{}, err
+	}
+	s := parseRows(rows, b)
+	if len(s.Positions) == 0 && len(s.Transactions) == 0 && len(s.Skipped) == 0 {
+		return s, errors.New("No " + b.Name + " positions or transactions found")
+	}
+	return s, nil
+}
+
+// Detect reads an export in whichever built-in format it's in.
+func Detect(r io.Reader) (Statement, error) {
+	rows, err := readRows(r, ',')
+	if err != nil {
+		return Statement{}, err
+	}
+	for _, name := range Names() {
+		s := parseRows(rows, Brokers[name])
+		if len(s.Positions) > 0 || len(s.Transactions) > 0 {
+			return s, nil
+		}
+	}
+	return Statement{}, errors.New("Not an export of a known broker, name the broker or an import profile")
+}
+
+func readRows(r io.Reader, comma rune) ([][]string, error) {
+	c := csv.NewReader(r)
+	c.Comma = comma
+	c.FieldsPerRecord = -1
+	c.LazyQuotes = true
+	c.TrimLeadingSpace = true
+	rows, err := c.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\uFEFF")
+	}
+	return rows, nil
+}
+
+// table is a Mapping matched against a header row.
+type table struct {
+	Mapping
+	section string
+	columns map[string]int
+}
+
+func (t *table) get(row []string, names string) string {
+	if i, ok := t.columns[names]; ok && i < len(row) {
+		return strings.TrimSpace(row[i])
+	}
+	return ""
+}
+
+// match returns the mapping with the most columns in header. Every column a
+// mapping names must be there, except the account.
+func match(header []string, mappings []Mapping) *table {
+	index := make(map[string]int)
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	find := func(names string) (int, bool) {
+		for _, name := range strings.Split(names, "|") {
+			if i, ok := index[strings.ToLower(name)]; ok {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	var best *table
+	for _, m := range mappings {
+		t := &table{Mapping: m, columns: make(map[string]int)}
+		ok := true
+		fields := []string{m.Symbol, m.Quantity, m.CostBasis, m.Date, m.Action, m.Price, m.Amount}
+		for name := range m.Only {
+			fields = append(fields, name)
+		}
+		for _, names := range fields {
+			if names == "" {
+				continue
+			}
+			i, found := find(names)
+			if !found {
+				ok = false
+				break
+			}
+			t.columns[names] = i
+		}
+		if i, found := find(m.Account); m.Account != "" && found {
+			t.columns[m.Account] = i
+		}
+		if ok && m.Symbol != "" && (best == nil || len(t.columns) > len(best.columns)) {
+			best = t
+		}
+	}
+	return best
+}
+
+func parseRows(rows [][]string, b Broker) Statement {
+	s := Statement{Broker: b.Name}
+	var t *table
+	for n, row := range rows {
+		line := n + 1
+		if b.sectioned {
+			// Section,Header|Data,...
+			if len(row) < 3 {
+				continue
+			}
+			section, kind := row[0], row[1]
+			if kind == "Header" {
+				header := make([]string, len(row)-2)
+				for i, h := range row[2:] {
+					header[i] = section + "/" + h
+				}
+				if t = match(header, b.Tables); t != nil {
+					t.section = section
+				}
+			} else if kind == "Data" && t != nil && t.section == section {
+				s.read(t, row[2:], line, b.classDots)
+			}
+			continue
+		}
+
+		if blank(row) {
+			t = nil
+			continue
+		}
+		if h := match(row, b.Tables); h != nil {
+			t = h
+			continue
+		}
+		if t != nil {
+			s.read(t, row, line, b.classDots)
+		}
+	}
+	return s
+}
+
+func (s *Statement) read(t *table, row []string, line int, classDots bool) {
+	for column, want := range t.Only {
+		if !strings.EqualFold(t.get(row, column), want) {
+			return
+		}
+	}
+	skip := func(format string, a ...interface{}) {
+		s.Skipped = append(s.Skipped, fmt.Sprintf("line %d: ", line)+fmt.Sprintf(format, a...))
+	}
+
+	raw := t.get(row, t.Symbol)
+	if raw == "" {
+		// Totals, notes and cash movements
+		return
+	}
+	symbol, err := normalize(raw, classDots)
+	if err != nil {
+		skip("%s", err)
+		return
+	}
+	account := t.get(row, t.Account)
+	quantity, err := number(t.get(row, t.Quantity))
+	if err != nil {
+		skip("%s quantity: %s", symbol, err)
+		return
+	}
+
+	if !t.transactions() {
+		cost, err := number(t.get(row, t.CostBasis))
+		if err != nil {
+			skip("%s cost basis: %s", symbol, err)
+			return
+		}
+		s.Positions = append(s.Positions, Position{Account: account, Symbol: symbol, Quantity: quantity, CostBasis: math.Abs(cost)})
+		return
+	}
+
+	date, err := parseDate(t.get(row, t.Date), t.DateFormat)
+	if err != nil {
+		skip("%s date: %s", symbol, err)
+		return
+	}
+	description := t.get(row, t.Action)
+	a := action(description, quantity)
+	if a == Other {
+		skip("%s %q is not a trade or dividend", symbol, description)
+		return
+	}
+	price, err := number(t.get(row, t.Price))
+	if err != nil {
+		skip("%s price: %s", symbol, err)
+		return
+	}
+	amount, err := number(t.get(row, t.Amount))
+	if err != nil {
+		skip("%s amount: %s", symbol, err)
+		return
+	}
+	s.Transactions = append(s.Transactions, Transaction{
+		Account:  account,
+		Date:     date,
+		Action:   a,
+		Symbol:   symbol,
+		Quantity: math.Abs(quantity),
+		Price:    math.Abs(price),
+		Amount:   amount,
+	})
+}
+
+func blank(row []string) bool {
+	for _, f := range row {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize turns a broker's symbol into Yahoo's, e.g. "BRK/B" or "BRK B",
+// or "BRK.B" with classDots, into "BRK-B". IBKR descriptions like
+// "AAPL(US0378331005) Cash Dividend" give their leading symbol.
+func normalize(symbol string, classDots bool) (string, error) {
+	s := strings.TrimSpace(symbol)
+	if i := strings.Index(s, "("); i > 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	s = strings.ToUpper(s)
+	if s == "" {
+		return "", errors.New("no symbol")
+	}
+	// Fidelity marks its money market core positions with **
+	if strings.HasSuffix(s, "**") {
+		return "", errors.New(symbol + " is cash")
+	}
+	s = shareClass.ReplaceAllString(strings.TrimRight(s, "*"), "$1-$2")
+	if classDots {
+		s = shareClassDot.ReplaceAllString(s, "$1-$2")
+	}
+	if !validSymbol.MatchString(s) {
+		return "", errors.New(symbol + " is not a security")
+	}
+	return s, nil
+}
+
+// number reads amounts like "$1,234.56", "(12.50)" or "-1.5". Empty cells
+// and placeholders like "--" are zero.
+func number(text string) (float64, error) {
+	t := strings.TrimSpace(text)
+	switch strings.ToLower(t) {
+	case "", "--", "-", "n/a", "na":
+		return 0, nil
+	}
+	negative := false
+	if strings.HasPrefix(t, "(") && strings.HasSuffix(t, ")") {
+		negative = true
+		t = t[1 : len(t)-1]
+	}
+	t = strings.NewReplacer("$", "", ",", "", " ", "", "+", "").Replace(t)
+	v, err := strconv.ParseFloat(t, 64)
+	if err != nil {
+		return 0, errors.New("not a number: " + text)
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+func parseDate(text, formats string) (time.Time, error) {
+	layouts := dateLayouts
+	if formats != "" {
+		layouts = strings.Split(formats, "|")
+	}
+	if text == "" {
+		return time.Time{}, errors.New("missing")
+	}
+	// Schwab writes dates like "03/01/2024 as of 02/29/2024"
+	if i := strings.Index(text, " as of "); i > 0 {
+		text = text[:i]
+	}
+	for _, layout := range layouts {
+		if d, err := time.Parse(layout, text); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, errors.New("unknown format " + text)
+}
@@ -3,8 +3,11 @@ package coingecko
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"net/url"
+	"strconv"
 	"time"
+
+	"github.com/anorb/lucrum/internal/apierror"
 )
 
 type CoinResponse struct {
@@ -98,6 +101,7 @@ type MarketData struct {
 	MarketCapChange24hInCurrency           Currencies           `json:"market_cap_change_24h_in_currency"`
 	MarketCapChangePercentage24hInCurrency Currencies           `json:"market_cap_change_percentage_24h_in_currency"`
 	FullyDilutedValuation                  Currencies           `json:"fully_diluted_valuation"`
+	SparklineIn7D                          Sparkline            `json:"sparkline_7d"`
 	TotalSupply                            float64              `json:"total_supply"`
 	MaxSupply                              float64              `json:"max_supply"`
 	CirculatingSupply                      float64              `json:"circulating_supply"`
@@ -122,16 +126,42 @@ type PublicInterestStats struct {
 	BingMatches int `json:"bing_matches"`
 }
 
-func FetchCoin(coin string) (CoinResponse, error) {
+// CoinOptions are the parameters of the /coins/{id} endpoint.
+type CoinOptions struct {
+	// Include the name in every language in Localization
+	Localization  bool
+	Tickers       bool
+	MarketData    bool
+	CommunityData bool
+	DeveloperData bool
+	// Include MarketData.SparklineIn7D
+	Sparkline bool
+}
+
+// DefaultCoinOptions fetches the coin's description and market data.
+var DefaultCoinOptions = CoinOptions{Localization: true, MarketData: true}
+
+func (o CoinOptions) values() url.Values {
+	v := url.Values{}
+	v.Set("localization", strconv.FormatBool(o.Localization))
+	v.Set("tickers", strconv.FormatBool(o.Tickers))
+	v.Set("market_data", strconv.FormatBool(o.MarketData))
+	v.Set("community_data", strconv.FormatBool(o.CommunityData))
+	v.Set("developer_data", strconv.FormatBool(o.DeveloperData))
+	v.Set("sparkline", strconv.FormatBool(o.Sparkline))
+	return v
+}
+
+func FetchCoin(coin string, opts CoinOptions) (CoinResponse, error) {
 	c := CoinResponse{}
 
-	body, err := makeCall(fmt.Sprintf("https://api.coingecko.com/api/v3/coins/%s?tickers=false&market_data=true&community_data=false&developer_data=false&sparkline=false", coin))
+	resp, err := makeCall(BaseURL + "/coins/" + url.PathEscape(coin) + "?" + opts.values().Encode())
 	if err != nil {
 		return c, err
 	}
 
-	if err = json.Unmarshal(body, &c); err != nil {
-		return c, errors.New("Failed to unmarshal: " + err.Error())
+	if err = json.Unmarshal(resp.Body, &c); err != nil {
+		return c, apierror.Malformed(resp, errors.New("Failed to unmarshal: "+err.Error()))
 	}
 
 	return c, nil
@@ -1,21 +1,36 @@
 package coingecko
 
 import (
-	"errors"
-	"io/ioutil"
-	"net/http"
+	"github.com/anorb/lucrum/internal/apierror"
+	"github.com/anorb/lucrum/pkg/fetch"
 )
 
-func makeCall(link string) ([]byte, error) {
-	resp, err := http.Get(link)
-	if err != nil {
-		return []byte{}, errors.New("Failed to get json: " + err.Error())
-	}
-	defer resp.Body.Close()
+const host = "api.coingecko.com"
+
+// BaseURL is where every request goes. Tests point it at a local server.
+var BaseURL = "https://" + host + "/api/v3"
+
+// RetryPolicy is used for every request to CoinGecko.
+var RetryPolicy = fetch.DefaultPolicy
+
+func init() {
+	// The public API allows roughly 10 to 30 calls a minute
+	SetRateLimit(10, 5)
+}
+
+// SetRateLimit limits requests to CoinGecko to perMinute with bursts of up
+// to burst requests.
+func SetRateLimit(perMinute float64, burst int) {
+	fetch.SetLimit(host, perMinute/60, burst)
+}
+
+func makeCall(link string) (fetch.Response, error) {
+	return apierror.Get(link, RetryPolicy)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return body, errors.New("Failed to read body: " + err.Error())
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
 	}
-	return body, nil
+	return s
 }
@@ -0,0 +1,465 @@
+package coingecko
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anorb/lucrum/internal/fakeapi"
+	"github.com/anorb/lucrum/pkg/fetch"
+)
+
+// fake points the package at a fake CoinGecko for the rest of the test.
+func fake(t *testing.T) *fakeapi.Server {
+	s := fakeapi.New(t)
+	base, policy := BaseURL, RetryPolicy
+	BaseURL = s.URL
+	RetryPolicy = fetch.Policy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	t.Cleanup(func() {
+		BaseURL, RetryPolicy = base, policy
+	})
+	return s
+}
+
+func TestFetchCoinList(t *testing.T) {
+	tests := []struct {
+		name     string
+		response fakeapi.Response
+		want     int
+		wantErr  error
+	}{
+		{"list", fakeapi.OK("coin_list.json"), 5, nil},
+		{"empty", fakeapi.Response{Body: "[]"}, 0, nil},
+		{"rate limited", fakeapi.RateLimited(0), 0, ErrRateLimited},
+		{"truncated", fakeapi.Response{Body: `[{"id":"bitcoin","sym`}, 0, ErrMalformed},
+		{"connection dropped", fakeapi.Response{Fixture: "coin_list.json", Truncate: true}, 0, ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fake(t)
+			s.Handle("/coins/list", tt.response)
+
+			coins, err := FetchCoinList()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if len(coins) != tt.want {
+				t.Errorf("got %d coins, want %d", len(coins), tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchMarkets(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      MarketsOptions
+		response  fakeapi.Response
+		wantQuery map[string]string
+		wantIDs   []string
+		wantErr   error
+	}{
+		{
+			name:     "defaults",
+			response: fakeapi.OK("markets.json"),
+			wantQuery: map[string]string{
+				"vs_currency":             "usd",
+				"order":                   "market_cap_desc",
+				"sparkline":               "false",
+				"price_change_percentage": "1h,24h,7d,14d,30d,200d,1y",
+			},
+			wantIDs: []string{"bitcoin", "ethereum"},
+		},
+		{
+			name: "every option",
+			opts: MarketsOptions{
+				VsCurrency:            "EUR",
+				IDs:                   []string{"bitcoin", "ethereum"},
+				Category:              "layer-1",
+				Order:                 "volume_desc",
+				PerPage:               2,
+				Page:                  3,
+				Sparkline:             true,
+				PriceChangePercentage: []string{"24h"},
+				Locale:                "de",
+				Precision:             "full",
+			},
+			response: fakeapi.OK("markets.json"),
+			wantQuery: map[string]string{
+				"vs_currency":             "eur",
+				"ids":                     "bitcoin,ethereum",
+				"category":                "layer-1",
+				"order":                   "volume_desc",
+				"per_page":                "2",
+				"page":                    "3",
+				"sparkline":               "true",
+				"price_change_percentage": "24h",
+				"locale":                  "de",
+				"precision":               "full",
+			},
+			wantIDs: []string{"bitcoin", "ethereum"},
+		},
+		{
+			name:    "too many per page",
+			opts:    MarketsOptions{PerPage: 251},
+			wantErr: errors.New("Results per page must be 250 or less"),
+		},
+		{
+			name:     "server error",
+			response: fakeapi.Status(http.StatusInternalServerError),
+			wantErr:  ErrUnavailable,
+		},
+		{
+			name:     "error object",
+			response: fakeapi.Response{Body: `{"error":"invalid vs_currency"}`},
+			wantErr:  ErrMalformed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fake(t)
+			s.Handle("/coins/markets", tt.response)
+
+			markets, err := FetchMarkets(tt.opts)
+			if tt.wantErr != nil {
+				if err == nil || !errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error() {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			query := s.Requests()[0].Query()
+			for k, want := range tt.wantQuery {
+				if got := query.Get(k); got != want {
+					t.Errorf("%s=%q, want %q", k, got, want)
+				}
+			}
+			var ids []string
+			for _, m := range markets {
+				ids = append(ids, m.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("got %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestFetchMarketsFields(t *testing.T) {
+	s := fake(t)
+	s.Handle("/coins/markets", fakeapi.OK("markets.json"))
+
+	markets, err := FetchMarkets(MarketsOptions{Sparkline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	btc, eth := markets[0], markets[1]
+	if got := btc.SparklineIn7D.Price; len(got) != 3 || got[2] != 36100 {
+		t.Errorf("sparkline %v", got)
+	}
+	if btc.PriceChangePercentage7DInCurrency != 2.5 || btc.AthDate.Year() != 2021 {
+		t.Errorf("got %+v", btc)
+	}
+	// Nulls leave zero values
+	if eth.MaxSupply != 0 || eth.FullyDilutedValuation != 0 || len(eth.SparklineIn7D.Price) != 0 {
+		t.Errorf("got %+v", eth)
+	}
+	if eth.ROI.Currency != "btc" || eth.ROI.Times != 79.1 {
+		t.Errorf("roi %+v", eth.ROI)
+	}
+}
+
+func TestFetchSimplePrice(t *testing.T) {
+	s := fake(t)
+	s.Handle("/simple/price", fakeapi.OK("simple_price.json"))
+
+	prices, err := FetchSimplePrice(SimplePriceOptions{
+		IDs:                []string{"bitcoin", "ethereum"},
+		VsCurrencies:       []string{"EUR", "usd"},
+		IncludeMarketCap:   true,
+		Include24HrVol:     true,
+		Include24HrChange:  true,
+		IncludeLastUpdated: true,
+		Precision:          "2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := s.Requests()[0].Query()
+	for k, want := range map[string]string{
+		"ids":                     "bitcoin,ethereum",
+		"vs_currencies":           "eur,usd",
+		"include_market_cap":      "true",
+		"include_24hr_vol":        "true",
+		"include_24hr_change":     "true",
+		"include_last_updated_at": "true",
+		"precision":               "2",
+	} {
+		if got := query.Get(k); got != want {
+			t.Errorf("%s=%q, want %q", k, got, want)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		get    func(id, currency string) (float64, bool)
+		id     string
+		want   float64
+		wantOK bool
+	}{
+		{"price", prices.Price, "bitcoin", 33210.5, true},
+		{"market cap", prices.MarketCap, "bitcoin", 648000000000, true},
+		{"volume", prices.Volume, "bitcoin", 16500000000, true},
+		{"change", prices.Change, "bitcoin", -1.38, true},
+		{"missing field", prices.Change, "ethereum", 0, false},
+		{"missing coin", prices.Price, "dogecoin", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.get(tt.id, "EUR")
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: got %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestFetchSimplePriceDefaults(t *testing.T) {
+	s := fake(t)
+	s.Handle("/simple/price", fakeapi.OK("simple_price.json"))
+
+	if _, err := FetchSimplePrice(SimplePriceOptions{IDs: []string{"bitcoin"}}); err != nil {
+		t.Fatal(err)
+	}
+	query := s.Requests()[0].Query()
+	if query.Get("vs_currencies") != "usd" || query.Get("include_market_cap") != "" {
+		t.Errorf("got query %v", query)
+	}
+}
+
+func TestFetchSimplePricePartial(t *testing.T) {
+	s := fake(t)
+	s.HandleFunc("/simple/price", func(r *http.Request) fakeapi.Response {
+		if strings.Contains(r.URL.Query().Get("ids"), "fail") {
+			return fakeapi.RateLimited(0)
+		}
+		return fakeapi.OK("simple_price.json")
+	})
+
+	ids := []string{"bitcoin"}
+	for i := 1; i < maxSimpleIDs; i++ {
+		ids = append(ids, fmt.Sprintf("coin-%d", i))
+	}
+	ids = append(ids, "fail")
+
+	prices, err := FetchSimplePrice(SimplePriceOptions{IDs: ids})
+	var batch *fetch.BatchError
+	if !errors.As(err, &batch) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got error %v, want a rate limited *fetch.BatchError", err)
+	}
+	if got := batch.Failed(); len(got) != 1 || got[0] != "fail" {
+		t.Errorf("failed %v, want [fail]", got)
+	}
+	if _, ok := prices.Price("bitcoin", "usd"); !ok {
+		t.Error("the first batch's prices should still be returned")
+	}
+}
+
+func TestFetchCoin(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        string
+		opts      CoinOptions
+		response  fakeapi.Response
+		wantQuery map[string]string
+		wantErr   error
+	}{
+		{
+			name:     "defaults",
+			id:       "bitcoin",
+			opts:     DefaultCoinOptions,
+			response: fakeapi.OK("coin_bitcoin.json"),
+			wantQuery: map[string]string{
+				"localization":   "true",
+				"tickers":        "false",
+				"market_data":    "true",
+				"community_data": "false",
+				"developer_data": "false",
+				"sparkline":      "false",
+			},
+		},
+		{
+			name:      "everything",
+			id:        "bitcoin",
+			opts:      CoinOptions{Tickers: true, MarketData: true, CommunityData: true, DeveloperData: true, Sparkline: true},
+			response:  fakeapi.OK("coin_bitcoin.json"),
+			wantQuery: map[string]string{"localization": "false", "tickers": "true", "sparkline": "true"},
+		},
+		{
+			name:     "unknown coin",
+			id:       "nope",
+			response: fakeapi.Response{Status: http.StatusNotFound, Body: `{"error":"coin not found"}`},
+			wantErr:  ErrNotFound,
+		},
+		{
+			name:     "rate limited",
+			id:       "bitcoin",
+			response: fakeapi.RateLimited(0),
+			wantErr:  ErrRateLimited,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fake(t)
+			s.Handle("/coins/"+tt.id, tt.response)
+
+			c, err := FetchCoin(tt.id, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			query := s.Requests()[0].Query()
+			for k, want := range tt.wantQuery {
+				if got := query.Get(k); got != want {
+					t.Errorf("%s=%q, want %q", k, got, want)
+				}
+			}
+			if c.Name != "Bitcoin" || c.Description["de"] == "" || c.Localization["zh"] != "比特币" {
+				t.Errorf("got %+v", c)
+			}
+			m := c.MarketData
+			if m.CurrentPrice["eur"] != 33210.5 || m.AthDate["usd"].Year() != 2021 || len(m.SparklineIn7D.Price) != 2 {
+				t.Errorf("market data %+v", m)
+			}
+			if c.Links.ReposURL.Github[0] != "https://github.com/bitcoin/bitcoin" {
+				t.Errorf("links %+v", c.Links)
+			}
+		})
+	}
+}
+
+func TestResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lucrum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "coinlist.json")
+
+	s := fake(t)
+	s.Handle("/coins/list", fakeapi.OK("coin_list.json"))
+	s.Handle("/coins/markets", fakeapi.OK("markets_uni.json"))
+
+	r := NewResolver(path)
+	tests := []struct {
+		ticker  string
+		want    string
+		wantErr error
+	}{
+		{"BTC", "bitcoin", nil},
+		{"eth", "ethereum", nil},
+		// Three coins share UNI, the largest wins
+		{"UNI", "uniswap", nil},
+		{"NOPE", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		got, err := r.Resolve(tt.ticker)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("Resolve(%s) = %q, %v, want %q, %v", tt.ticker, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	ranked, err := r.Rank("UNI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range ranked {
+		ids = append(ids, c.ID)
+	}
+	// Coins without market data come last
+	if strings.Join(ids, ",") != "uniswap,unicorn-token,universe-token" {
+		t.Errorf("ranked %v", ids)
+	}
+
+	// A second resolver reads the cache instead of fetching
+	lists := func() int {
+		n := 0
+		for _, u := range s.Requests() {
+			if u.Path == "/coins/list" {
+				n++
+			}
+		}
+		return n
+	}
+	before := lists()
+	if _, err := NewResolver(path).Resolve("BTC"); err != nil {
+		t.Fatal(err)
+	}
+	if lists() != before {
+		t.Error("a fresh cache should not be fetched again")
+	}
+
+	// A stale cache is refetched, and still used if that fails
+	stale := NewResolver(path)
+	stale.MaxAge = 0
+	s.Handle("/coins/list", fakeapi.Status(http.StatusBadGateway))
+	if id, err := stale.Resolve("BTC"); err != nil || id != "bitcoin" {
+		t.Errorf("got %q, %v from a stale cache", id, err)
+	}
+	if lists() == before {
+		t.Error("a stale cache should be fetched again")
+	}
+	// After a failure it backs off instead of downloading on every lookup
+	before = lists()
+	if _, err := stale.Resolve("ETH"); err != nil {
+		t.Fatal(err)
+	}
+	if lists() != before {
+		t.Error("fetched again right after a failure")
+	}
+
+	// Without market data the candidates come unranked
+	s.Handle("/coins/markets", fakeapi.Status(http.StatusBadGateway))
+	ranked, err = r.Rank("UNI")
+	if err != nil || len(ranked) != 3 {
+		t.Errorf("got %v, %v without market data", ranked, err)
+	}
+}
+
+func TestResolverOneDownload(t *testing.T) {
+	s := fake(t)
+	release := make(chan struct{})
+	s.HandleFunc("/coins/list", func(r *http.Request) fakeapi.Response {
+		<-release
+		return fakeapi.OK("coin_list.json")
+	})
+
+	r := NewResolver("")
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if coins, err := r.List(); err != nil || len(coins) == 0 {
+				t.Errorf("got %d coins, %v", len(coins), err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := len(s.Requests()); n != 1 {
+		t.Errorf("%d downloads, want 1", n)
+	}
+}
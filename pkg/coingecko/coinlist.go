@@ -3,24 +3,28 @@ package coingecko
 import (
 	"encoding/json"
 	"errors"
+
+	"github.com/anorb/lucrum/internal/apierror"
 )
 
-type CoinList []struct {
+type Coin struct {
 	ID     string `json:"id"`
 	Symbol string `json:"symbol"`
 	Name   string `json:"name"`
 }
 
+type CoinList []Coin
+
 func FetchCoinList() (CoinList, error) {
 	cl := CoinList{}
 
-	body, err := makeCall("https://api.coingecko.com/api/v3/coins/list")
+	resp, err := makeCall(BaseURL + "/coins/list")
 	if err != nil {
 		return cl, err
 	}
 
-	if err = json.Unmarshal(body, &cl); err != nil {
-		return cl, errors.New("Failed to unmarshal: " + err.Error())
+	if err = json.Unmarshal(resp.Body, &cl); err != nil {
+		return cl, apierror.Malformed(resp, errors.New("Failed to unmarshal: "+err.Error()))
 	}
 
 	return cl, nil
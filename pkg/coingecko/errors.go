@@ -0,0 +1,15 @@
+package coingecko
+
+import "github.com/anorb/lucrum/internal/apierror"
+
+// Errors are matched with errors.Is, the same values for every provider.
+var (
+	ErrRateLimited  = apierror.ErrRateLimited
+	ErrNotFound     = apierror.ErrNotFound
+	ErrUnauthorized = apierror.ErrUnauthorized
+	ErrUnavailable  = apierror.ErrUnavailable
+	ErrMalformed    = apierror.ErrMalformed
+)
+
+// Error describes a failed request, see errors.As.
+type Error = apierror.Error
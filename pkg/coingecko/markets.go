@@ -3,8 +3,12 @@ package coingecko
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/anorb/lucrum/internal/apierror"
 )
 
 type MarketsResponse struct {
@@ -41,21 +45,86 @@ type MarketsResponse struct {
 	PriceChangePercentage24HInCurrency  float64   `json:"price_change_percentage_24h_in_currency"`
 	PriceChangePercentage30DInCurrency  float64   `json:"price_change_percentage_30d_in_currency"`
 	PriceChangePercentage7DInCurrency   float64   `json:"price_change_percentage_7d_in_currency"`
+	SparklineIn7D                       Sparkline `json:"sparkline_in_7d"`
+}
+
+// Sparkline is a week of prices, oldest first, included when requested.
+type Sparkline struct {
+	Price []float64 `json:"price"`
+}
+
+// MarketsOptions are the parameters of the /coins/markets endpoint. The zero
+// value lists the first page of coins by market cap in USD.
+type MarketsOptions struct {
+	// Currency the prices and market data are in, "usd" by default
+	VsCurrency string
+	// Only include these coin IDs
+	IDs []string
+	// Only include coins in a category, e.g. "decentralized-finance-defi"
+	Category string
+	// "market_cap_desc" by default, or e.g. "volume_desc", "id_asc"
+	Order   string
+	PerPage int
+	Page    int
+	// Include SparklineIn7D
+	Sparkline bool
+	// Periods of the *InCurrency change fields, e.g. "1h", "7d". All of
+	// them are requested when empty.
+	PriceChangePercentage []string
+	// Language of the coin names, e.g. "de"
+	Locale string
+	// Decimal places of the prices, or "full"
+	Precision string
+}
+
+// AllPriceChanges lists every period MarketsOptions.PriceChangePercentage
+// accepts.
+var AllPriceChanges = []string{"1h", "24h", "7d", "14d", "30d", "200d", "1y"}
+
+func (o MarketsOptions) values() url.Values {
+	v := url.Values{}
+	v.Set("vs_currency", orDefault(strings.ToLower(o.VsCurrency), "usd"))
+	if len(o.IDs) > 0 {
+		v.Set("ids", strings.Join(o.IDs, ","))
+	}
+	if o.Category != "" {
+		v.Set("category", o.Category)
+	}
+	v.Set("order", orDefault(o.Order, "market_cap_desc"))
+	if o.PerPage > 0 {
+		v.Set("per_page", strconv.Itoa(o.PerPage))
+	}
+	if o.Page > 0 {
+		v.Set("page", strconv.Itoa(o.Page))
+	}
+	v.Set("sparkline", strconv.FormatBool(o.Sparkline))
+	changes := o.PriceChangePercentage
+	if len(changes) == 0 {
+		changes = AllPriceChanges
+	}
+	v.Set("price_change_percentage", strings.Join(changes, ","))
+	if o.Locale != "" {
+		v.Set("locale", o.Locale)
+	}
+	if o.Precision != "" {
+		v.Set("precision", o.Precision)
+	}
+	return v
 }
 
-func FetchMarkets(resultsPerPage, displayPage int) ([]MarketsResponse, error) {
+func FetchMarkets(opts MarketsOptions) ([]MarketsResponse, error) {
 	m := []MarketsResponse{}
 
-	if resultsPerPage > 250 {
+	if opts.PerPage > 250 {
 		return m, errors.New("Results per page must be 250 or less")
 	}
 
-	body, err := makeCall(fmt.Sprintf("https://api.coingecko.com/api/v3/coins/markets?vs_currency=usd&order=market_cap_desc&per_page=%d&page=%d&sparkline=false&price_change_percentage=%s", resultsPerPage, displayPage, "1h,24h,7d,14d,30d,200d,1y"))
+	resp, err := makeCall(BaseURL + "/coins/markets?" + opts.values().Encode())
 	if err != nil {
 		return m, err
 	}
-	if err = json.Unmarshal(body, &m); err != nil {
-		return m, errors.New("Failed to unmarshal market response: " + err.Error())
+	if err = json.Unmarshal(resp.Body, &m); err != nil {
+		return m, apierror.Malformed(resp, errors.New("Failed to unmarshal market response: "+err.Error()))
 	}
 
 	return m, nil
@@ -0,0 +1,210 @@
+package coingecko
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxAge is how long a cached coin list is used before it is
+// fetched again. New coins are listed every day but rarely matter at once.
+const DefaultMaxAge = 24 * time.Hour
+
+// After a failed download the list isn't fetched again for this long, so
+// going offline doesn't mean a download attempt on every lookup.
+const failureBackoff = 5 * time.Minute
+
+// Resolver maps tickers like "BTC" to CoinGecko IDs like "bitcoin". The
+// coin list is several megabytes, so it is kept in a file and only fetched
+// again once it is older than MaxAge.
+type Resolver struct {
+	Path   string
+	MaxAge time.Duration
+
+	mutex   sync.Mutex
+	coins   CoinList
+	fetched time.Time
+	// The last failed download and its error
+	failed  time.Time
+	lastErr error
+	// Closed when the download in flight finishes, nil without one
+	done chan struct{}
+}
+
+// Candidate is a coin sharing the ticker being resolved.
+type Candidate struct {
+	Coin
+	// Zero for coins without market data
+	MarketCapRank int64
+	MarketCap     int64
+}
+
+// NewResolver returns a Resolver caching the coin list at path. An empty
+// path keeps the list in memory only.
+func NewResolver(path string) *Resolver {
+	return &Resolver{Path: path, MaxAge: DefaultMaxAge}
+}
+
+// List returns the coin list, from memory or the cache file while it's
+// fresh. If fetching a new list fails, a stale one is still returned. The
+// download happens without holding the lock: callers with a stale list
+// keep using it meanwhile and callers without one wait for it.
+func (r *Resolver) List() (CoinList, error) {
+	r.mutex.Lock()
+	if r.coins == nil && r.Path != "" {
+		r.load()
+	}
+	for {
+		if r.coins != nil && time.Since(r.fetched) < r.MaxAge {
+			defer r.mutex.Unlock()
+			return r.coins, nil
+		}
+		if time.Since(r.failed) < failureBackoff {
+			defer r.mutex.Unlock()
+			if r.coins != nil {
+				return r.coins, nil
+			}
+			return nil, r.lastErr
+		}
+		if r.done == nil {
+			break
+		}
+		if r.coins != nil {
+			defer r.mutex.Unlock()
+			return r.coins, nil
+		}
+		done := r.done
+		r.mutex.Unlock()
+		<-done
+		r.mutex.Lock()
+	}
+	done := make(chan struct{})
+	r.done = done
+	r.mutex.Unlock()
+
+	coins, err := FetchCoinList()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.done = nil
+	close(done)
+	if err != nil {
+		r.failed, r.lastErr = time.Now(), err
+		if r.coins != nil {
+			return r.coins, nil
+		}
+		return nil, err
+	}
+	r.coins, r.fetched = coins, time.Now()
+	r.failed, r.lastErr = time.Time{}, nil
+	if r.Path != "" {
+		r.save()
+	}
+	return coins, nil
+}
+
+func (r *Resolver) load() {
+	info, err := os.Stat(r.Path)
+	if err != nil {
+		return
+	}
+	body, err := ioutil.ReadFile(r.Path)
+	if err != nil {
+		return
+	}
+	var coins CoinList
+	if json.Unmarshal(body, &coins) != nil {
+		return
+	}
+	r.coins, r.fetched = coins, info.ModTime()
+}
+
+// save writes the list to a temporary file first so a crash can't leave a
+// truncated cache behind. The cache is only an optimisation, so failures
+// are ignored.
+func (r *Resolver) save() {
+	body, err := json.Marshal(r.coins)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+		return
+	}
+	tmp := r.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return
+	}
+	if os.Rename(tmp, r.Path) != nil {
+		os.Remove(tmp)
+	}
+}
+
+// Candidates returns the coins whose ticker is symbol, in list order.
+func (r *Resolver) Candidates(symbol string) ([]Coin, error) {
+	coins, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []Coin
+	for _, c := range coins {
+		if strings.EqualFold(c.Symbol, symbol) {
+			matches = append(matches, c)
+		}
+	}
+	return matches, nil
+}
+
+// Rank returns the coins whose ticker is symbol, largest market cap first.
+// Coins without market data come last, in list order, as do all of them
+// if the market data can't be fetched. It returns ErrNotFound if no coin
+// has the ticker.
+func (r *Resolver) Rank(symbol string) ([]Candidate, error) {
+	coins, err := r.Candidates(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if len(coins) == 0 {
+		return nil, &Error{Kind: ErrNotFound, Err: errors.New("no coin with ticker " + symbol)}
+	}
+	if len(coins) == 1 {
+		return []Candidate{{Coin: coins[0]}}, nil
+	}
+
+	var ids []string
+	byID := make(map[string]Coin)
+	for _, c := range coins {
+		ids = append(ids, c.ID)
+		byID[c.ID] = c
+	}
+	// Without market data the candidates are unranked, which still lets
+	// the user pick
+	markets, _ := FetchMarkets(MarketsOptions{IDs: ids, PerPage: 250, PriceChangePercentage: []string{"24h"}})
+
+	var ranked []Candidate
+	for _, m := range markets {
+		if c, ok := byID[m.ID]; ok {
+			ranked = append(ranked, Candidate{Coin: c, MarketCapRank: m.MarketCapRank, MarketCap: m.MarketCap})
+			delete(byID, m.ID)
+		}
+	}
+	for _, c := range coins {
+		if _, ok := byID[c.ID]; ok {
+			ranked = append(ranked, Candidate{Coin: c})
+		}
+	}
+	return ranked, nil
+}
+
+// Resolve returns the ID of the largest coin whose ticker is symbol.
+func (r *Resolver) Resolve(symbol string) (string, error) {
+	ranked, err := r.Rank(symbol)
+	if err != nil {
+		return "", err
+	}
+	return ranked[0].ID, nil
+}
@@ -3,22 +3,117 @@ package coingecko
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"net/url"
 	"strings"
+	"sync"
+
+	"github.com/anorb/lucrum/internal/apierror"
+	"github.com/anorb/lucrum/pkg/fetch"
 )
 
+// SimpleResponse maps coin IDs to their values keyed by currency, e.g.
+// "eur", "eur_market_cap", "eur_24h_vol", "eur_24h_change" and
+// "last_updated_at".
 type SimpleResponse map[string]map[string]float64
 
-func FetchSimplePrice(coins []string) (SimpleResponse, error) {
+const (
+	maxSimpleIDs    = 100
+	maxSimpleLength = 1500
+)
+
+// SimplePriceOptions are the parameters of the /simple/price endpoint. The
+// zero value fetches USD prices of IDs.
+type SimplePriceOptions struct {
+	IDs []string
+	// "usd" when empty
+	VsCurrencies       []string
+	IncludeMarketCap   bool
+	Include24HrVol     bool
+	Include24HrChange  bool
+	IncludeLastUpdated bool
+	// Decimal places of the prices, or "full"
+	Precision string
+}
+
+func (o SimplePriceOptions) values(ids []string) url.Values {
+	v := url.Values{}
+	v.Set("ids", strings.Join(ids, ","))
+	currencies := []string{"usd"}
+	if len(o.VsCurrencies) > 0 {
+		currencies = o.VsCurrencies
+	}
+	v.Set("vs_currencies", strings.ToLower(strings.Join(currencies, ",")))
+	if o.IncludeMarketCap {
+		v.Set("include_market_cap", "true")
+	}
+	if o.Include24HrVol {
+		v.Set("include_24hr_vol", "true")
+	}
+	if o.Include24HrChange {
+		v.Set("include_24hr_change", "true")
+	}
+	if o.IncludeLastUpdated {
+		v.Set("include_last_updated_at", "true")
+	}
+	if o.Precision != "" {
+		v.Set("precision", o.Precision)
+	}
+	return v
+}
+
+// Price returns a coin's price in currency.
+func (s SimpleResponse) Price(id, currency string) (float64, bool) {
+	return s.value(id, strings.ToLower(currency))
+}
+
+// MarketCap returns a coin's market cap in currency, if it was requested.
+func (s SimpleResponse) MarketCap(id, currency string) (float64, bool) {
+	return s.value(id, strings.ToLower(currency)+"_market_cap")
+}
+
+// Volume returns a coin's 24 hour volume in currency, if it was requested.
+func (s SimpleResponse) Volume(id, currency string) (float64, bool) {
+	return s.value(id, strings.ToLower(currency)+"_24h_vol")
+}
+
+// Change returns a coin's 24 hour change in percent, if it was requested.
+func (s SimpleResponse) Change(id, currency string) (float64, bool) {
+	return s.value(id, strings.ToLower(currency)+"_24h_change")
+}
+
+func (s SimpleResponse) value(id, key string) (float64, bool) {
+	v, ok := s[id][key]
+	return v, ok
+}
+
+// FetchSimplePrice fetches prices for opts.IDs in batches fetched
+// concurrently. If some batches fail, the prices from the others are still
+// returned along with a *fetch.BatchError.
+func FetchSimplePrice(opts SimplePriceOptions) (SimpleResponse, error) {
+	var mutex sync.Mutex
+	s := SimpleResponse{}
+	err := fetch.Each(fetch.Chunk(opts.IDs, maxSimpleIDs, maxSimpleLength), func(chunk []string) error {
+		prices, err := fetchSimplePriceChunk(chunk, opts)
+		mutex.Lock()
+		defer mutex.Unlock()
+		for id, p := range prices {
+			s[id] = p
+		}
+		return err
+	})
+	return s, err
+}
+
+func fetchSimplePriceChunk(ids []string, opts SimplePriceOptions) (SimpleResponse, error) {
 	s := SimpleResponse{}
 
-	body, err := makeCall(fmt.Sprintf("https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=usd", strings.Join(coins[:], ",")))
+	resp, err := makeCall(BaseURL + "/simple/price?" + opts.values(ids).Encode())
 	if err != nil {
 		return s, err
 	}
 
-	if err = json.Unmarshal(body, &s); err != nil {
-		return s, errors.New("Failed to unmarshal: " + err.Error())
+	if err = json.Unmarshal(resp.Body, &s); err != nil {
+		return s, apierror.Malformed(resp, errors.New("Failed to unmarshal: "+err.Error()))
 	}
 
 	return s, nil
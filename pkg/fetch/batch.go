@@ -0,0 +1,126 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Workers bounds how many chunks are fetched at once.
+var Workers = 4
+
+// Chunk splits items into batches of at most size items whose joined
+// length (with a one character separator) stays within maxLength. A
+// maxLength of zero means no length limit.
+func Chunk(items []string, size, maxLength int) [][]string {
+	var chunks [][]string
+	var current []string
+	length := 0
+	for _, item := range items {
+		full := size > 0 && len(current) >= size
+		tooLong := maxLength > 0 && len(current) > 0 && length+1+len(item) > maxLength
+		if full || tooLong {
+			chunks = append(chunks, current)
+			current, length = nil, 0
+		}
+		if len(current) > 0 {
+			length++
+		}
+		current = append(current, item)
+		length += len(item)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// ChunkError reports a failed chunk and the items it contained.
+type ChunkError struct {
+	Items []string
+	Err   error
+}
+
+func (e ChunkError) Error() string {
+	return fmt.Sprintf("%s: %s", strings.Join(e.Items, ","), e.Err)
+}
+
+// BatchError collects the chunks that failed in a batch. It matches any of
+// its chunks' errors with errors.Is and errors.As.
+type BatchError struct {
+	Chunks []ChunkError
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, len(e.Chunks))
+	for i, c := range e.Chunks {
+		msgs[i] = c.Error()
+	}
+	return fmt.Sprintf("%d of the requests failed: %s", len(e.Chunks), strings.Join(msgs, "; "))
+}
+
+func (e *BatchError) Is(target error) bool {
+	for _, c := range e.Chunks {
+		if errors.Is(c.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *BatchError) As(target interface{}) bool {
+	for _, c := range e.Chunks {
+		if errors.As(c.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed returns every item that belonged to a failed chunk.
+func (e *BatchError) Failed() []string {
+	var items []string
+	for _, c := range e.Chunks {
+		items = append(items, c.Items...)
+	}
+	return items
+}
+
+// Each calls fn for every chunk using at most Workers goroutines. Errors
+// are collected into a *BatchError in chunk order, or nil if all succeeded.
+func Each(chunks [][]string, fn func(chunk []string) error) error {
+	errs := make([]error, len(chunks))
+	work := make(chan int)
+	var wg sync.WaitGroup
+
+	workers := Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers && w < len(chunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				errs[i] = fn(chunks[i])
+			}
+		}()
+	}
+	for i := range chunks {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	batch := &BatchError{}
+	for i, err := range errs {
+		if err != nil {
+			batch.Chunks = append(batch.Chunks, ChunkError{Items: chunks[i], Err: err})
+		}
+	}
+	if len(batch.Chunks) == 0 {
+		return nil
+	}
+	return batch
+}
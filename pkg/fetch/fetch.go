@@ -0,0 +1,125 @@
+package fetch
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Policy controls how failed requests are retried. Only rate limited (429)
+// and server error (5xx) responses are retried.
+type Policy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+var DefaultPolicy = Policy{MaxRetries: 2, BaseDelay: 500 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// ErrRateLimited is returned instead of waiting out a pause longer than the
+// policy's MaxDelay, e.g. after a Retry-After of several minutes.
+var ErrRateLimited = errors.New("rate limited, paused by the server")
+
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Get requests link, waiting on the host's limiter before every attempt.
+// The last response is returned when retries run out or when the server
+// asks to wait longer than the policy's MaxDelay; the caller decides what
+// to make of its status code. Requests to a host paused for longer than
+// MaxDelay fail with ErrRateLimited without waiting.
+func Get(link string, policy Policy) (Response, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return Response{}, err
+	}
+	limiter := limiterFor(u.Host)
+
+	for attempt := 0; ; attempt++ {
+		if err := limiter.Wait(policy.MaxDelay); err != nil {
+			return Response{}, err
+		}
+		resp, err := get(link)
+		if err != nil {
+			return resp, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			limiter.limited()
+		}
+
+		delay := Backoff(attempt, policy.BaseDelay, policy.MaxDelay)
+		if after, ok := RetryAfter(resp.Header, time.Now()); ok {
+			limiter.Pause(time.Now().Add(after))
+			if after > policy.MaxDelay {
+				return resp, nil
+			}
+			delay = after
+		} else if resp.StatusCode == http.StatusTooManyRequests {
+			limiter.Pause(time.Now().Add(delay))
+		}
+		if attempt >= policy.MaxRetries {
+			return resp, nil
+		}
+		limiter.retried()
+		time.Sleep(delay)
+	}
+}
+
+func get(link string) (Response, error) {
+	resp, err := http.Get(link)
+	if err != nil {
+		return Response{}, errors.New("Failed to get json: " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	r := Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+	if err != nil {
+		return r, errors.New("Failed to read body: " + err.Error())
+	}
+	return r, nil
+}
+
+// Backoff returns an exponential delay with full jitter for the given
+// attempt, starting at base and capped at max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := base << uint(attempt)
+	if d <= 0 {
+		d = base
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// RetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func RetryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
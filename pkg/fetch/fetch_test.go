@@ -0,0 +1,168 @@
+package fetch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	base, max := 100*time.Millisecond, time.Second
+	for attempt := 0; attempt < 8; attempt++ {
+		ceiling := base << uint(attempt)
+		if ceiling > max {
+			ceiling = max
+		}
+		for i := 0; i < 50; i++ {
+			if d := Backoff(attempt, base, max); d <= 0 || d > ceiling {
+				t.Fatalf("Backoff(%d) = %s, want within (0, %s]", attempt, d, ceiling)
+			}
+		}
+	}
+	if d := Backoff(3, 0, max); d != 0 {
+		t.Errorf("Backoff without a base = %s, want 0", d)
+	}
+	// Shifting past the width of a Duration must not go negative
+	if d := Backoff(70, base, 0); d <= 0 {
+		t.Errorf("Backoff(70) = %s, want positive", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 4, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"0", 0, true},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		h := http.Header{}
+		if test.header != "" {
+			h.Set("Retry-After", test.header)
+		}
+		got, ok := RetryAfter(h, now)
+		if got != test.want || ok != test.ok {
+			t.Errorf("RetryAfter(%q) = %s, %v, want %s, %v", test.header, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(100, 2)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The burst goes straight through, the third waits for a token
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Errorf("three requests took %s, want the third throttled", elapsed)
+	}
+	if b := l.budget("host"); b.Requests != 3 || b.Throttled != 1 {
+		t.Errorf("got %d requests, %d throttled, want 3 and 1", b.Requests, b.Throttled)
+	}
+}
+
+func TestLimiterPause(t *testing.T) {
+	l := NewLimiter(0, 1)
+	l.Pause(time.Now().Add(time.Hour))
+	start := time.Now()
+	if err := l.Wait(time.Second); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited", err)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("waited out a pause longer than maxWait")
+	}
+
+	// A short pause is waited out, and an earlier one doesn't shorten it
+	l = NewLimiter(0, 1)
+	l.Pause(time.Now().Add(20 * time.Millisecond))
+	l.Pause(time.Now())
+	start = time.Now()
+	if err := l.Wait(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 15*time.Millisecond {
+		t.Error("didn't wait for the pause")
+	}
+}
+
+// server answers with the statuses in turn, the last one from then on.
+func server(t *testing.T, retryAfter string, statuses ...int) (string, *int32) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1)) - 1
+		if n >= len(statuses) {
+			n = len(statuses) - 1
+		}
+		if retryAfter != "" && statuses[n] != http.StatusOK {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(statuses[n])
+	}))
+	t.Cleanup(s.Close)
+	return s.URL, &calls
+}
+
+func budgetOf(t *testing.T, link string) Budget {
+	u, _ := url.Parse(link)
+	for _, b := range Budgets() {
+		if b.Host == u.Host {
+			return b
+		}
+	}
+	t.Fatalf("no budget for %s", u.Host)
+	return Budget{}
+}
+
+func TestGet(t *testing.T) {
+	policy := Policy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+	link, calls := server(t, "", http.StatusTooManyRequests, http.StatusOK)
+	resp, err := Get(link, policy)
+	if err != nil || resp.StatusCode != http.StatusOK || *calls != 2 {
+		t.Errorf("got %d, %v after %d calls, want 200 after 2", resp.StatusCode, err, *calls)
+	}
+	if b := budgetOf(t, link); b.RateLimited != 1 || b.Retries != 1 {
+		t.Errorf("got %d 429s and %d retries, want 1 and 1", b.RateLimited, b.Retries)
+	}
+
+	// Server errors are retried but aren't 429s
+	link, calls = server(t, "0", http.StatusServiceUnavailable)
+	resp, _ = Get(link, policy)
+	if resp.StatusCode != http.StatusServiceUnavailable || *calls != 3 {
+		t.Errorf("got %d after %d calls, want 503 after 3", resp.StatusCode, *calls)
+	}
+	if b := budgetOf(t, link); b.RateLimited != 0 {
+		t.Errorf("counted %d 503s as 429s", b.RateLimited)
+	}
+
+	// A long Retry-After gives up at once and so do later requests
+	link, calls = server(t, "3600", http.StatusTooManyRequests)
+	resp, err = Get(link, policy)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests || *calls != 1 {
+		t.Errorf("got %d, %v after %d calls, want 429 after 1", resp.StatusCode, err, *calls)
+	}
+	start := time.Now()
+	if _, err := Get(link, policy); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got %v while paused, want ErrRateLimited", err)
+	}
+	if time.Since(start) > time.Second || *calls != 1 {
+		t.Error("waited out the pause")
+	}
+	if b := budgetOf(t, link); time.Until(b.PausedUntil) < 59*time.Minute {
+		t.Errorf("paused until %s, want an hour from now", b.PausedUntil)
+	}
+}
@@ -0,0 +1,160 @@
+package fetch
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket that refills at rate tokens per second up to
+// burst tokens. A zero rate means unlimited.
+type Limiter struct {
+	mutex       sync.Mutex
+	rate        float64
+	burst       int
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+
+	requests    int
+	throttled   int
+	rateLimited int
+	retries     int
+}
+
+// Budget is a snapshot of a host's limiter and request counters.
+type Budget struct {
+	Host        string
+	Rate        float64
+	Burst       int
+	Available   float64
+	PausedUntil time.Time
+	Requests    int
+	Throttled   int
+	RateLimited int
+	Retries     int
+}
+
+var (
+	limitersMutex sync.Mutex
+	limiters      = make(map[string]*Limiter)
+)
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{rate: rate, burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// SetLimit configures the limiter used for every request to host.
+func SetLimit(host string, rate float64, burst int) {
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+	limiters[host] = NewLimiter(rate, burst)
+}
+
+func limiterFor(host string) *Limiter {
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+	l, ok := limiters[host]
+	if !ok {
+		l = NewLimiter(0, 1)
+		limiters[host] = l
+	}
+	return l
+}
+
+// Budgets returns the state of every host's limiter, sorted by host.
+func Budgets() []Budget {
+	limitersMutex.Lock()
+	hosts := make([]string, 0, len(limiters))
+	for host := range limiters {
+		hosts = append(hosts, host)
+	}
+	limitersMutex.Unlock()
+	sort.Strings(hosts)
+
+	budgets := make([]Budget, len(hosts))
+	for i, host := range hosts {
+		budgets[i] = limiterFor(host).budget(host)
+	}
+	return budgets
+}
+
+// Wait blocks until a request may be made. If the host is paused for
+// longer than maxWait it returns ErrRateLimited straight away instead; a
+// zero maxWait waits however long the pause is.
+func (l *Limiter) Wait(maxWait time.Duration) error {
+	l.mutex.Lock()
+	wait := time.Until(l.pausedUntil)
+	if maxWait > 0 && wait > maxWait {
+		l.mutex.Unlock()
+		return ErrRateLimited
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	l.requests++
+	if l.rate > 0 {
+		l.refill(time.Now())
+		l.tokens--
+		if l.tokens < 0 {
+			wait += time.Duration(-l.tokens / l.rate * float64(time.Second))
+		}
+		if wait > 0 {
+			l.throttled++
+		}
+	}
+	l.mutex.Unlock()
+
+	time.Sleep(wait)
+	return nil
+}
+
+// Pause stops requests to the host until t, as asked for by Retry-After.
+func (l *Limiter) Pause(t time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if t.After(l.pausedUntil) {
+		l.pausedUntil = t
+	}
+}
+
+// limited counts a 429 response.
+func (l *Limiter) limited() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.rateLimited++
+}
+
+func (l *Limiter) retried() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.retries++
+}
+
+func (l *Limiter) refill(now time.Time) {
+	l.tokens = math.Min(float64(l.burst), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+func (l *Limiter) budget(host string) Budget {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.rate > 0 {
+		l.refill(time.Now())
+	}
+	return Budget{
+		Host:        host,
+		Rate:        l.rate,
+		Burst:       l.burst,
+		Available:   math.Max(0, l.tokens),
+		PausedUntil: l.pausedUntil,
+		Requests:    l.requests,
+		Throttled:   l.throttled,
+		RateLimited: l.rateLimited,
+		Retries:     l.retries,
+	}
+}
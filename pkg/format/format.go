@@ -0,0 +1,114 @@
+package format
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+const maxPrecision = 8
+
+var suffixes = []struct {
+	threshold float64
+	suffix    string
+}{
+	{1e3, "K"},
+	{1e6, "M"},
+	{1e9, "B"},
+	{1e12, "T"},
+}
+
+// Precision returns the number of decimals needed to show v meaningfully.
+// hint is the provider's suggestion (Yahoo's priceHint), values below one
+// get enough decimals to keep four significant digits.
+func Precision(v float64, hint int) int {
+	p := hint
+	if p <= 0 {
+		p = 2
+	}
+	a := math.Abs(v)
+	if a > 0 && a < 1 {
+		sig := int(math.Ceil(-math.Log10(a))) + 3
+		if sig > p {
+			p = sig
+		}
+	}
+	if p > maxPrecision {
+		p = maxPrecision
+	}
+	return p
+}
+
+// Signs of the common currencies, keyed by lowercase ISO code
+var currencySigns = map[string]string{
+	"usd": "$",
+	"eur": "€",
+	"gbp": "£",
+	"jpy": "¥",
+	"cny": "¥",
+	"inr": "₹",
+	"krw": "₩",
+	"chf": "CHF ",
+	"btc": "₿",
+}
+
+// Sign returns the sign written before amounts in currency, falling back to
+// the uppercase code, e.g. "SEK ".
+func Sign(currency string) string {
+	currency = strings.ToLower(currency)
+	if s, ok := currencySigns[currency]; ok {
+		return s
+	}
+	if currency == "" {
+		return "$"
+	}
+	return strings.ToUpper(currency) + " "
+}
+
+func Cash(v float64, decimals int) string {
+	return Money(v, decimals, "usd")
+}
+
+// Money is Cash in another currency.
+func Money(v float64, decimals int, currency string) string {
+	return signed(v, Sign(currency), strconv.FormatFloat(math.Abs(v), 'f', decimals, 64))
+}
+
+func Number(v float64, decimals int) string {
+	return signed(v, "", strconv.FormatFloat(math.Abs(v), 'f', decimals, 64))
+}
+
+func Percentage(p float64) string {
+	return Number(p, 2) + "%"
+}
+
+// Abbreviate shortens large values to K/M/B/T, e.g. 1234567 -> 1.23M. The
+// unit is picked after rounding, so 999999 is 1.00M rather than 1000.00K.
+func Abbreviate(v float64) string {
+	a := math.Abs(v)
+	if math.Round(a) < 1e3 {
+		return Number(v, 0)
+	}
+	for i, s := range suffixes {
+		scaled := math.Round(a/s.threshold*100) / 100
+		if scaled < 1e3 || i == len(suffixes)-1 {
+			return signed(v, "", strconv.FormatFloat(scaled, 'f', 2, 64)+s.suffix)
+		}
+	}
+	return Number(v, 0)
+}
+
+func AbbreviateCash(v float64) string {
+	return AbbreviateMoney(v, "usd")
+}
+
+func AbbreviateMoney(v float64, currency string) string {
+	return signed(v, Sign(currency), strings.TrimPrefix(Abbreviate(v), "-"))
+}
+
+func signed(v float64, prefix, digits string) string {
+	if v < 0 && strings.Trim(digits, "0.KMBT") != "" {
+		return "-" + prefix + digits
+	}
+	return prefix + digits
+}
@@ -0,0 +1,69 @@
+package format
+
+import "testing"
+
+func TestAbbreviate(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{999.4, "999"},
+		{999.6, "1.00K"},
+		{1000, "1.00K"},
+		{1234, "1.23K"},
+		{999994, "999.99K"},
+		{999999, "1.00M"},
+		{1234567, "1.23M"},
+		{999999999, "1.00B"},
+		{999999999999, "1.00T"},
+		{2.5e15, "2500.00T"},
+		{-999999, "-1.00M"},
+		{-0.4, "0"},
+	}
+	for _, test := range tests {
+		if got := Abbreviate(test.v); got != test.want {
+			t.Errorf("Abbreviate(%v) = %q, want %q", test.v, got, test.want)
+		}
+	}
+}
+
+func TestMoney(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{Cash(1234.5, 2), "$1234.50"},
+		{Cash(-0.001, 2), "$0.00"},
+		{Cash(-3, 2), "-$3.00"},
+		{Money(12, 0, "EUR"), "€12"},
+		{Money(12, 0, "sek"), "SEK 12"},
+		{AbbreviateCash(-1234567), "-$1.23M"},
+		{AbbreviateMoney(999999, "gbp"), "£1.00M"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("got %q, want %q", test.got, test.want)
+		}
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	tests := []struct {
+		v    float64
+		hint int
+		want int
+	}{
+		{172.87, 2, 2},
+		{172.87, 0, 2},
+		{1.2345, 4, 4},
+		{0.5, 2, 4},
+		{0.00123, 2, 6},
+		{0.0000000012, 2, maxPrecision},
+	}
+	for _, test := range tests {
+		if got := Precision(test.v, test.hint); got != test.want {
+			t.Errorf("Precision(%v, %d) = %d, want %d", test.v, test.hint, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package indicators
+
+import (
+	"math"
+	"time"
+)
+
+type Candle struct {
+	Time   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// All series returned by this package have the same length as their input
+// so they can be drawn directly over the candles. Points that cannot be
+// computed yet (the warmup period) are NaN.
+
+func Closes(candles []Candle) []float64 {
+	closes := make([]float64, len(candles))
+	for i, c := range candles {
+		closes[i] = c.Close
+	}
+	return closes
+}
+
+// Last returns the most recent value of a series, or NaN if there is none.
+func Last(series []float64) float64 {
+	for i := len(series) - 1; i >= 0; i-- {
+		if !math.IsNaN(series[i]) {
+			return series[i]
+		}
+	}
+	return math.NaN()
+}
+
+func SMA(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 {
+		return out
+	}
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// EMA is seeded with the SMA of the first period values.
+func EMA(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 || len(values) < period {
+		return out
+	}
+	k := 2 / float64(period+1)
+	prev := 0.0
+	for i := 0; i < period; i++ {
+		prev += values[i]
+	}
+	prev /= float64(period)
+	out[period-1] = prev
+	for i := period; i < len(values); i++ {
+		prev = values[i]*k + prev*(1-k)
+		out[i] = prev
+	}
+	return out
+}
+
+// RSI uses Wilder's smoothing.
+func RSI(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 || len(values) <= period {
+		return out
+	}
+	gain, loss := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		d := values[i] - values[i-1]
+		if d > 0 {
+			gain += d
+		} else {
+			loss -= d
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	out[period] = rsi(gain, loss)
+	for i := period + 1; i < len(values); i++ {
+		d := values[i] - values[i-1]
+		g, l := 0.0, 0.0
+		if d > 0 {
+			g = d
+		} else {
+			l = -d
+		}
+		gain = (gain*float64(period-1) + g) / float64(period)
+		loss = (loss*float64(period-1) + l) / float64(period)
+		out[i] = rsi(gain, loss)
+	}
+	return out
+}
+
+func rsi(gain, loss float64) float64 {
+	if loss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+func MACD(values []float64, fast, slow, signal int) (macd, sig, hist []float64) {
+	fastEMA := EMA(values, fast)
+	slowEMA := EMA(values, slow)
+	macd = nanSeries(len(values))
+	for i := range values {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+
+	sig = nanSeries(len(values))
+	start := firstValid(macd)
+	if start >= 0 {
+		copy(sig[start:], EMA(macd[start:], signal))
+	}
+
+	hist = nanSeries(len(values))
+	for i := range values {
+		hist[i] = macd[i] - sig[i]
+	}
+	return macd, sig, hist
+}
+
+func BollingerBands(values []float64, period int, k float64) (upper, middle, lower []float64) {
+	middle = SMA(values, period)
+	upper = nanSeries(len(values))
+	lower = nanSeries(len(values))
+	for i := period - 1; i < len(values) && period > 0; i++ {
+		variance := 0.0
+		for _, v := range values[i-period+1 : i+1] {
+			variance += (v - middle[i]) * (v - middle[i])
+		}
+		dev := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + k*dev
+		lower[i] = middle[i] - k*dev
+	}
+	return upper, middle, lower
+}
+
+// ATR uses Wilder's smoothing of the true range.
+func ATR(candles []Candle, period int) []float64 {
+	out := nanSeries(len(candles))
+	if period <= 0 || len(candles) <= period {
+		return out
+	}
+	tr := make([]float64, len(candles))
+	for i, c := range candles {
+		tr[i] = c.High - c.Low
+		if i > 0 {
+			prev := candles[i-1].Close
+			tr[i] = math.Max(tr[i], math.Max(math.Abs(c.High-prev), math.Abs(c.Low-prev)))
+		}
+	}
+	atr := 0.0
+	for i := 1; i <= period; i++ {
+		atr += tr[i]
+	}
+	atr /= float64(period)
+	out[period] = atr
+	for i := period + 1; i < len(candles); i++ {
+		atr = (atr*float64(period-1) + tr[i]) / float64(period)
+		out[i] = atr
+	}
+	return out
+}
+
+// VWAP is cumulative over the candles given, so callers pass a single
+// session's intraday candles, see Spec.Intraday.
+func VWAP(candles []Candle) []float64 {
+	out := nanSeries(len(candles))
+	pv, vol := 0.0, 0.0
+	for i, c := range candles {
+		typical := (c.High + c.Low + c.Close) / 3
+		pv += typical * c.Volume
+		vol += c.Volume
+		if vol > 0 {
+			out[i] = pv / vol
+		}
+	}
+	return out
+}
+
+func nanSeries(n int) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = math.NaN()
+	}
+	return s
+}
+
+func firstValid(series []float64) int {
+	for i, v := range series {
+		if !math.IsNaN(v) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,119 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) < 1e-9
+}
+
+func equal(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d points, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if !near(got[i], want[i]) {
+			t.Errorf("%s[%d] = %v, want %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestSMA(t *testing.T) {
+	nan := math.NaN()
+	equal(t, "SMA(3)", SMA([]float64{1, 2, 3, 4, 5}, 3), []float64{nan, nan, 2, 3, 4})
+	equal(t, "SMA(6)", SMA([]float64{1, 2, 3}, 6), []float64{nan, nan, nan})
+	equal(t, "SMA(0)", SMA([]float64{1, 2}, 0), []float64{nan, nan})
+}
+
+func TestEMA(t *testing.T) {
+	nan := math.NaN()
+	// Seeded with the SMA of 2, 4, 6, then k = 0.5
+	equal(t, "EMA(3)", EMA([]float64{2, 4, 6, 8, 4}, 3), []float64{nan, nan, 4, 6, 5})
+}
+
+func TestRSI(t *testing.T) {
+	rising := []float64{1, 2, 3, 4, 5}
+	if got := Last(RSI(rising, 3)); got != 100 {
+		t.Errorf("RSI of a rising series = %v, want 100", got)
+	}
+	// Gains of 1 and losses of 1 average out
+	if got := Last(RSI([]float64{1, 2, 1, 2, 1}, 4)); !near(got, 50) {
+		t.Errorf("RSI of a flat series = %v, want 50", got)
+	}
+	if got := Last(RSI(rising, 5)); !math.IsNaN(got) {
+		t.Errorf("RSI without enough values = %v, want NaN", got)
+	}
+}
+
+func TestBollingerBands(t *testing.T) {
+	upper, middle, lower := BollingerBands([]float64{1, 3, 1, 3}, 2, 2)
+	nan := math.NaN()
+	equal(t, "middle", middle, []float64{nan, 2, 2, 2})
+	equal(t, "upper", upper, []float64{nan, 4, 4, 4})
+	equal(t, "lower", lower, []float64{nan, 0, 0, 0})
+}
+
+func TestVWAP(t *testing.T) {
+	candles := []Candle{
+		{High: 11, Low: 9, Close: 10, Volume: 100},
+		{High: 21, Low: 19, Close: 20, Volume: 300},
+		{High: 30, Low: 30, Close: 30, Volume: 0},
+	}
+	equal(t, "VWAP", VWAP(candles), []float64{10, 17.5, 17.5})
+	if got := VWAP([]Candle{{Close: 5}}); !math.IsNaN(got[0]) {
+		t.Errorf("VWAP without volume = %v, want NaN", got[0])
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{"SMA", "SMA(20)", false},
+		{" sma(50) ", "SMA(50)", false},
+		{"MACD(12,26)", "MACD(12,26,9)", false},
+		{"BB(20,2.5)", "BB(20,2.5)", false},
+		{"VWAP", "VWAP", false},
+		{"SMA(0)", "", true},
+		{"SMA(-3)", "", true},
+		{"SMA(2.5)", "", true},
+		{"BB(20,0)", "", true},
+		{"SMA(x)", "", true},
+		{"SMA(1,2)", "", true},
+		{"SMA(20", "", true},
+		{"FOO", "", true},
+	}
+	for _, test := range tests {
+		spec, err := ParseSpec(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseSpec(%q) = %s, want an error", test.in, spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSpec(%q): %s", test.in, err)
+		} else if spec.String() != test.want {
+			t.Errorf("ParseSpec(%q) = %s, want %s", test.in, spec, test.want)
+		}
+	}
+}
+
+func TestIntraday(t *testing.T) {
+	vwap, _ := ParseSpec("VWAP")
+	sma, _ := ParseSpec("SMA")
+	if !vwap.Intraday() || vwap.Overlay() {
+		t.Error("VWAP should be intraday and not a daily overlay")
+	}
+	if sma.Intraday() || !sma.Overlay() {
+		t.Error("SMA should be a daily overlay")
+	}
+}
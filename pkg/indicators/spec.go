@@ -0,0 +1,130 @@
+package indicators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Spec describes an indicator and its parameters as written in the config,
+// for example "RSI(14)", "MACD(12,26,9)" or "BB(20,2)".
+type Spec struct {
+	Name   string
+	Params []float64
+}
+
+var defaults = map[string][]float64{
+	"SMA":  {20},
+	"EMA":  {20},
+	"RSI":  {14},
+	"MACD": {12, 26, 9},
+	"BB":   {20, 2},
+	"ATR":  {14},
+	"VWAP": {},
+}
+
+func ParseSpec(s string) (Spec, error) {
+	spec := Spec{}
+	s = strings.TrimSpace(s)
+	name := s
+	args := ""
+	if i := strings.Index(s, "("); i != -1 {
+		if !strings.HasSuffix(s, ")") {
+			return spec, errors.New("Missing closing parenthesis in " + s)
+		}
+		name = s[:i]
+		args = s[i+1 : len(s)-1]
+	}
+	spec.Name = strings.ToUpper(strings.TrimSpace(name))
+
+	def, ok := defaults[spec.Name]
+	if !ok {
+		return spec, errors.New("Unknown indicator: " + spec.Name)
+	}
+	spec.Params = append([]float64{}, def...)
+	if args != "" {
+		for i, a := range strings.Split(args, ",") {
+			if i >= len(spec.Params) {
+				return spec, fmt.Errorf("Too many parameters for %s", spec.Name)
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+			if err != nil {
+				return spec, errors.New("Invalid parameter for " + spec.Name + ": " + err.Error())
+			}
+			// Only the band width of BB is a multiplier, the rest are periods
+			if v <= 0 || (v != math.Trunc(v) && !(spec.Name == "BB" && i == 1)) {
+				return spec, fmt.Errorf("Invalid parameter for %s: %s is not a positive whole number", spec.Name, strings.TrimSpace(a))
+			}
+			spec.Params[i] = v
+		}
+	}
+	return spec, nil
+}
+
+func (s Spec) String() string {
+	if len(s.Params) == 0 {
+		return s.Name
+	}
+	params := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		params[i] = strconv.FormatFloat(p, 'f', -1, 64)
+	}
+	return s.Name + "(" + strings.Join(params, ",") + ")"
+}
+
+// Overlay reports whether the indicator is on the price scale and can be
+// drawn over a daily price chart, as opposed to an oscillator. VWAP is on
+// the price scale but only means something within a session.
+func (s Spec) Overlay() bool {
+	switch s.Name {
+	case "SMA", "EMA", "BB":
+		return true
+	}
+	return false
+}
+
+// Intraday reports whether the indicator is computed over a single
+// session's intraday candles rather than daily ones.
+func (s Spec) Intraday() bool {
+	return s.Name == "VWAP"
+}
+
+// Series computes the indicator. The first series is the main line, the
+// others are secondary lines such as the MACD signal or Bollinger bands.
+func (s Spec) Series(candles []Candle) [][]float64 {
+	closes := Closes(candles)
+	switch s.Name {
+	case "SMA":
+		return [][]float64{SMA(closes, s.param(0))}
+	case "EMA":
+		return [][]float64{EMA(closes, s.param(0))}
+	case "RSI":
+		return [][]float64{RSI(closes, s.param(0))}
+	case "MACD":
+		macd, sig, hist := MACD(closes, s.param(0), s.param(1), s.param(2))
+		return [][]float64{macd, sig, hist}
+	case "BB":
+		upper, middle, lower := BollingerBands(closes, s.param(0), s.Params[1])
+		return [][]float64{middle, upper, lower}
+	case "ATR":
+		return [][]float64{ATR(candles, s.param(0))}
+	case "VWAP":
+		return [][]float64{VWAP(candles)}
+	}
+	return nil
+}
+
+// Value returns the latest value of the indicator's main line.
+func (s Spec) Value(candles []Candle) float64 {
+	series := s.Series(candles)
+	if len(series) == 0 {
+		return Last(nil)
+	}
+	return Last(series[0])
+}
+
+func (s Spec) param(i int) int {
+	return int(s.Params[i])
+}
@@ -0,0 +1,117 @@
+package yahoofinance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/anorb/lucrum/internal/apierror"
+)
+
+type Candle struct {
+	Time   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Dividend is a cash dividend per share, dated by its ex-dividend date.
+type Dividend struct {
+	Date   time.Time
+	Amount float64
+}
+
+type Chart struct {
+	Symbol    string
+	Candles   []Candle
+	Dividends []Dividend
+}
+
+type chartQuery struct {
+	Chart struct {
+		Result []struct {
+			Meta struct {
+				Symbol string `json:"symbol"`
+			} `json:"meta"`
+			Timestamp  []int64 `json:"timestamp"`
+			Indicators struct {
+				Quote []struct {
+					Open   []*float64 `json:"open"`
+					High   []*float64 `json:"high"`
+					Low    []*float64 `json:"low"`
+					Close  []*float64 `json:"close"`
+					Volume []*float64 `json:"volume"`
+				} `json:"quote"`
+			} `json:"indicators"`
+			Events struct {
+				Dividends map[string]struct {
+					Amount float64 `json:"amount"`
+					Date   int64   `json:"date"`
+				} `json:"dividends"`
+			} `json:"events"`
+		} `json:"result"`
+		Error *json.RawMessage `json:"error"`
+	} `json:"chart"`
+}
+
+// FetchChart fetches candles and dividends for symbol. chartRange and
+// interval take Yahoo's values, e.g. "1y" and "1d". Dividends don't depend
+// on the interval.
+func FetchChart(symbol, chartRange, interval string) (Chart, error) {
+	c := Chart{Symbol: symbol}
+	q := chartQuery{}
+
+	resp, err := makeCall(fmt.Sprintf("%s/v8/finance/chart/%s?range=%s&interval=%s&events=div", BaseURL, url.PathEscape(symbol), chartRange, interval))
+	if err != nil {
+		return c, err
+	}
+	if err = json.Unmarshal(resp.Body, &q); err != nil {
+		return c, apierror.Malformed(resp, errors.New("Failed to unmarshal chart: "+err.Error()))
+	}
+
+	if q.Chart.Error != nil {
+		return c, responseError(resp, *q.Chart.Error)
+	}
+	if len(q.Chart.Result) == 0 {
+		return c, nil
+	}
+
+	r := q.Chart.Result[0]
+	for _, d := range r.Events.Dividends {
+		c.Dividends = append(c.Dividends, Dividend{Date: time.Unix(d.Date, 0), Amount: d.Amount})
+	}
+	sort.Slice(c.Dividends, func(i, j int) bool {
+		return c.Dividends[i].Date.Before(c.Dividends[j].Date)
+	})
+	if len(r.Indicators.Quote) == 0 {
+		return c, nil
+	}
+	quote := r.Indicators.Quote[0]
+	for i, ts := range r.Timestamp {
+		// Yahoo returns nulls for periods without trades
+		if i >= len(quote.Close) || quote.Close[i] == nil {
+			continue
+		}
+		c.Candles = append(c.Candles, Candle{
+			Time:   time.Unix(ts, 0),
+			Open:   value(quote.Open, i),
+			High:   value(quote.High, i),
+			Low:    value(quote.Low, i),
+			Close:  *quote.Close[i],
+			Volume: value(quote.Volume, i),
+		})
+	}
+	return c, nil
+}
+
+func value(series []*float64, i int) float64 {
+	if i >= len(series) || series[i] == nil {
+		return 0
+	}
+	return *series[i]
+}
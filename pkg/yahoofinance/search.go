@@ -0,0 +1,47 @@
+package yahoofinance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/anorb/lucrum/internal/apierror"
+)
+
+type SearchResult struct {
+	Symbol    string `json:"symbol"`
+	ShortName string `json:"shortname"`
+	LongName  string `json:"longname"`
+	Exchange  string `json:"exchange"`
+	ExchDisp  string `json:"exchDisp"`
+	QuoteType string `json:"quoteType"`
+	TypeDisp  string `json:"typeDisp"`
+}
+
+type searchQuery struct {
+	Quotes []SearchResult `json:"quotes"`
+}
+
+// Name returns the most descriptive name Yahoo gave for the result.
+func (r SearchResult) Name() string {
+	if r.LongName != "" {
+		return r.LongName
+	}
+	return r.ShortName
+}
+
+// Search looks up equities, funds and other instruments by ticker or name.
+func Search(query string) ([]SearchResult, error) {
+	q := searchQuery{}
+
+	resp, err := makeCall(fmt.Sprintf("%s/v1/finance/search?q=%s&quotesCount=10&newsCount=0", SearchBaseURL, url.QueryEscape(query)))
+	if err != nil {
+		return q.Quotes, err
+	}
+	if err = json.Unmarshal(resp.Body, &q); err != nil {
+		return q.Quotes, apierror.Malformed(resp, errors.New("Failed to unmarshal search: "+err.Error()))
+	}
+
+	return q.Quotes, nil
+}
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strings"
+	"sync"
+
+	"github.com/anorb/lucrum/internal/apierror"
+	"github.com/anorb/lucrum/pkg/fetch"
+	"github.com/anorb/lucrum/pkg/format"
 )
 
 type Stock struct {
@@ -80,59 +83,165 @@ type Stock struct {
 	PreMarketChangePercent            float64 `json:"preMarketChangePercent"`
 	PreMarketTime                     int     `json:"preMarketTime"`
 	PreMarketPrice                    float64 `json:"preMarketPrice"`
+	TrailingAnnualDividendRate        float64 `json:"trailingAnnualDividendRate"`
+	TrailingAnnualDividendYield       float64 `json:"trailingAnnualDividendYield"`
+	DividendRate                      float64 `json:"dividendRate"`
+	DividendYield                     float64 `json:"dividendYield"`
+	DividendDate                      int64   `json:"dividendDate"`
+	ExDividendDate                    int64   `json:"exDividendDate"`
 	FormattedRegularMarketPrice       string
 	FormattedRegularMarketChange      string
 	FormattedRegularMarketChangePct   string
 	FormattedRegularMarketDayHigh     string
 	FormattedRegularMarketDayLow      string
 	FormattedRegularMarketDayOpen     string
+	FormattedRegularMarketVolume      string
+	FormattedMarketCap                string
 }
 
 type Query struct {
 	Quote struct {
-		Result []Stock     `json:"result"`
-		Error  interface{} `json:"error"`
+		Result []Stock          `json:"result"`
+		Error  *json.RawMessage `json:"error"`
 	} `json:"quoteResponse"`
 }
 
+var hosts = []string{"query1.finance.yahoo.com", "query2.finance.yahoo.com"}
+
+// Where quotes, charts and searches are requested from. Tests point these
+// at a local server.
+var (
+	BaseURL       = "https://query1.finance.yahoo.com"
+	SearchBaseURL = "https://query2.finance.yahoo.com"
+)
+
+// RetryPolicy is used for every request to Yahoo.
+var RetryPolicy = fetch.DefaultPolicy
+
+func init() {
+	SetRateLimit(60, 10)
+}
+
+// SetRateLimit limits requests to each Yahoo host to perMinute with bursts
+// of up to burst requests.
+func SetRateLimit(perMinute float64, burst int) {
+	for _, host := range hosts {
+		fetch.SetLimit(host, perMinute/60, burst)
+	}
+}
+
+func makeCall(link string) (fetch.Response, error) {
+	return apierror.Get(link, RetryPolicy)
+}
+
+// responseError converts the error member of a Yahoo response, which is
+// either a plain string or an object with a code and description.
+func responseError(resp fetch.Response, raw json.RawMessage) error {
+	var message string
+	if err := json.Unmarshal(raw, &message); err == nil {
+		return &Error{Kind: ErrUnavailable, StatusCode: resp.StatusCode, Snippet: message}
+	}
+
+	var obj struct {
+		Code        string `json:"code"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return apierror.Malformed(fetch.Response{StatusCode: resp.StatusCode, Body: raw}, errors.New("Failed to unmarshal error: "+err.Error()))
+	}
+	kind := ErrUnavailable
+	switch strings.ToLower(obj.Code) {
+	case "not found":
+		kind = ErrNotFound
+	case "unauthorized", "forbidden":
+		kind = ErrUnauthorized
+	case "too many requests":
+		kind = ErrRateLimited
+	}
+	return &Error{Kind: kind, StatusCode: resp.StatusCode, Snippet: obj.Description}
+}
+
+// Yahoo rejects quote requests with too many symbols or too long a URL
+const (
+	maxQuoteSymbols = 50
+	maxQuoteLength  = 1500
+)
+
+// FetchQuote quotes symbols in batches fetched concurrently. Stocks are
+// returned in the order of symbols. If some batches fail, the stocks from
+// the others are still returned along with a *fetch.BatchError.
 func FetchQuote(symbols []string) ([]Stock, error) {
+	var mutex sync.Mutex
+	bySymbol := make(map[string]Stock)
+	err := fetch.Each(fetch.Chunk(symbols, maxQuoteSymbols, maxQuoteLength), func(chunk []string) error {
+		stocks, err := fetchQuoteChunk(chunk)
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, s := range stocks {
+			bySymbol[strings.ToUpper(s.Symbol)] = s
+		}
+		return err
+	})
+
+	var stocks []Stock
+	for _, sym := range symbols {
+		if s, ok := bySymbol[strings.ToUpper(sym)]; ok {
+			stocks = append(stocks, s)
+		}
+	}
+	return stocks, err
+}
+
+func fetchQuoteChunk(symbols []string) ([]Stock, error) {
 	q := Query{}
 
-	resp, err := http.Get(fmt.Sprintf("https://query1.finance.yahoo.com/v7/finance/quote?symbols=%s", strings.Join(symbols[:], ",")))
+	resp, err := makeCall(fmt.Sprintf("%s/v7/finance/quote?symbols=%s", BaseURL, strings.Join(symbols[:], ",")))
 	if err != nil {
-		return q.Quote.Result, errors.New("Failed to get json: " + err.Error())
+		return q.Quote.Result, err
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return q.Quote.Result, errors.New("Failed to read body: " + err.Error())
+	if err = json.Unmarshal(resp.Body, &q); err != nil {
+		return q.Quote.Result, apierror.Malformed(resp, errors.New("Failed to unmarshal: "+err.Error()))
 	}
-	json.Unmarshal(body, &q)
 
 	if q.Quote.Error != nil {
-		return q.Quote.Result, errors.New(q.Quote.Error.(string))
+		return q.Quote.Result, responseError(resp, *q.Quote.Error)
 	}
 
 	for i, s := range q.Quote.Result {
-		s.FormattedRegularMarketPrice = formatCash(s.RegularMarketPrice)
-		s.FormattedRegularMarketChange = formatCash(s.RegularMarketChange)
-		s.FormattedRegularMarketChangePct = formatPercentage(s.RegularMarketChangePercent)
-		s.FormattedRegularMarketDayHigh = formatCash(s.RegularMarketDayHigh)
-		s.FormattedRegularMarketDayLow = formatCash(s.RegularMarketDayLow)
-		s.FormattedRegularMarketDayOpen = formatCash(s.RegularMarketOpen)
-		q.Quote.Result[i] = s
+		q.Quote.Result[i] = formatStock(s)
 	}
 
 	return q.Quote.Result, nil
 }
 
-func formatPercentage(p float64) string {
-	return fmt.Sprintf("%.2f%%", p)
+// Precision returns the number of decimals used for the stock's prices.
+func (s Stock) Precision() int {
+	hint := s.PriceHint
+	if s.QuoteType == "CURRENCY" && hint < 4 {
+		hint = 4
+	}
+	return format.Precision(s.RegularMarketPrice, hint)
+}
+
+// FormatPrice formats a value denominated in the stock's price, such as
+// its change or day range, with the stock's precision.
+func (s Stock) FormatPrice(v float64) string {
+	if s.QuoteType == "CURRENCY" {
+		return format.Number(v, s.Precision())
+	}
+	return format.Cash(v, s.Precision())
 }
 
-func formatCash(c float64) string {
-	if c < 0 {
-		return fmt.Sprintf("-$%.2f", c*-1)
+func formatStock(s Stock) Stock {
+	s.FormattedRegularMarketPrice = s.FormatPrice(s.RegularMarketPrice)
+	s.FormattedRegularMarketChange = s.FormatPrice(s.RegularMarketChange)
+	s.FormattedRegularMarketChangePct = format.Percentage(s.RegularMarketChangePercent)
+	s.FormattedRegularMarketDayHigh = s.FormatPrice(s.RegularMarketDayHigh)
+	s.FormattedRegularMarketDayLow = s.FormatPrice(s.RegularMarketDayLow)
+	s.FormattedRegularMarketDayOpen = s.FormatPrice(s.RegularMarketOpen)
+	s.FormattedRegularMarketVolume = format.Abbreviate(float64(s.RegularMarketVolume))
+	if s.MarketCap > 0 {
+		s.FormattedMarketCap = format.AbbreviateCash(float64(s.MarketCap))
 	}
-	return fmt.Sprintf("$%.2f", c)
+	return s
 }
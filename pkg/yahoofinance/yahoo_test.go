@@ -0,0 +1,267 @@
+package yahoofinance
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anorb/lucrum/internal/fakeapi"
+	"github.com/anorb/lucrum/pkg/fetch"
+)
+
+// fake points the package at a fake Yahoo for the rest of the test.
+func fake(t *testing.T) *fakeapi.Server {
+	s := fakeapi.New(t)
+	base, search, policy := BaseURL, SearchBaseURL, RetryPolicy
+	BaseURL, SearchBaseURL = s.URL, s.URL
+	RetryPolicy = fetch.Policy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	t.Cleanup(func() {
+		BaseURL, SearchBaseURL, RetryPolicy = base, search, policy
+	})
+	return s
+}
+
+const quotePath = "/v7/finance/quote"
+
+func TestFetchQuote(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []fakeapi.Response
+		symbols   []string
+		want      []string
+		wantErr   error
+	}{
+		{
+			name:      "quotes in the order asked",
+			responses: []fakeapi.Response{fakeapi.OK("quote.json")},
+			symbols:   []string{"BTC-USD", "AAPL"},
+			want:      []string{"BTC-USD", "AAPL"},
+		},
+		{
+			name:      "symbols missing from the response are dropped",
+			responses: []fakeapi.Response{fakeapi.OK("quote.json")},
+			symbols:   []string{"AAPL", "NOPE", "BTC-USD"},
+			want:      []string{"AAPL", "BTC-USD"},
+		},
+		{
+			name:      "empty result",
+			responses: []fakeapi.Response{fakeapi.OK("quote_empty.json")},
+			symbols:   []string{"NOPE"},
+		},
+		{
+			name:      "null fields",
+			responses: []fakeapi.Response{fakeapi.OK("quote_nulls.json")},
+			symbols:   []string{"XYZ"},
+			want:      []string{"XYZ"},
+		},
+		{
+			name:      "error object",
+			responses: []fakeapi.Response{fakeapi.OK("quote_error_object.json")},
+			symbols:   []string{"AAPL"},
+			wantErr:   ErrNotFound,
+		},
+		{
+			name:      "error string",
+			responses: []fakeapi.Response{fakeapi.OK("quote_error_string.json")},
+			symbols:   []string{"AAPL"},
+			wantErr:   ErrUnavailable,
+		},
+		{
+			name:      "unauthorized",
+			responses: []fakeapi.Response{{Status: http.StatusUnauthorized, Fixture: "unauthorized.json"}},
+			symbols:   []string{"AAPL"},
+			wantErr:   ErrUnauthorized,
+		},
+		{
+			name:      "rate limited until retries run out",
+			responses: []fakeapi.Response{fakeapi.RateLimited(0)},
+			symbols:   []string{"AAPL"},
+			wantErr:   ErrRateLimited,
+		},
+		{
+			name:      "rate limited then served",
+			responses: []fakeapi.Response{fakeapi.RateLimited(0), fakeapi.OK("quote.json")},
+			symbols:   []string{"AAPL"},
+			want:      []string{"AAPL"},
+		},
+		{
+			name:      "server error",
+			responses: []fakeapi.Response{fakeapi.Status(http.StatusBadGateway)},
+			symbols:   []string{"AAPL"},
+			wantErr:   ErrUnavailable,
+		},
+		{
+			name:      "truncated JSON",
+			responses: []fakeapi.Response{{Body: `{"quoteResponse":{"result":[{"symbol":"AA`}},
+			symbols:   []string{"AAPL"},
+			wantErr:   ErrMalformed,
+		},
+		{
+			name:      "connection dropped mid-body",
+			responses: []fakeapi.Response{{Fixture: "quote.json", Truncate: true}},
+			symbols:   []string{"AAPL"},
+			wantErr:   ErrUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fake(t)
+			s.Handle(quotePath, tt.responses...)
+
+			stocks, err := FetchQuote(tt.symbols)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := symbols(stocks); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchQuoteFormats(t *testing.T) {
+	s := fake(t)
+	s.Handle(quotePath, fakeapi.OK("quote.json"))
+
+	stocks, err := FetchQuote([]string{"AAPL", "BTC-USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		got, want string
+	}{
+		{stocks[0].FormattedRegularMarketPrice, "$172.87"},
+		{stocks[0].FormattedRegularMarketChange, "$1.23"},
+		{stocks[0].FormattedRegularMarketChangePct, "0.72%"},
+		{stocks[0].FormattedMarketCap, "$2.70T"},
+		{stocks[1].FormattedRegularMarketChange, "-$512.50"},
+		{stocks[1].FormattedRegularMarketVolume, "18.00B"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestFetchQuotePartial(t *testing.T) {
+	s := fake(t)
+	// The second batch fails, the first still comes back
+	s.HandleFunc(quotePath, func(r *http.Request) fakeapi.Response {
+		if strings.Contains(r.URL.Query().Get("symbols"), "FAIL") {
+			return fakeapi.Status(http.StatusServiceUnavailable)
+		}
+		return fakeapi.OK("quote_aapl.json")
+	})
+
+	requested := []string{"AAPL"}
+	for i := 1; i < maxQuoteSymbols; i++ {
+		requested = append(requested, fmt.Sprintf("S%d", i))
+	}
+	requested = append(requested, "FAIL1", "FAIL2")
+
+	stocks, err := FetchQuote(requested)
+	var batch *fetch.BatchError
+	if !errors.As(err, &batch) {
+		t.Fatalf("got error %v, want a *fetch.BatchError", err)
+	}
+	if got := strings.Join(batch.Failed(), ","); got != "FAIL1,FAIL2" {
+		t.Errorf("failed symbols %s, want FAIL1,FAIL2", got)
+	}
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("error %v should match ErrUnavailable", err)
+	}
+	if len(stocks) != 1 || stocks[0].Symbol != "AAPL" {
+		t.Errorf("got %v, want the first batch's AAPL", symbols(stocks))
+	}
+	// The failed batch is retried once
+	if n := len(s.Requests()); n != 3 {
+		t.Errorf("made %d requests, want 3", n)
+	}
+}
+
+func TestFetchChart(t *testing.T) {
+	s := fake(t)
+	s.Handle("/v8/finance/chart/AAPL", fakeapi.OK("chart.json"))
+
+	chart, err := FetchChart("AAPL", "1y", "1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chart.Candles) != 3 {
+		t.Fatalf("got %d candles, want 3", len(chart.Candles))
+	}
+	if c := chart.Candles[2]; c.Open != 0 || c.Close != 189.7 {
+		t.Errorf("a null open should be zero, got %+v", c)
+	}
+	if d := chart.Dividends; len(d) != 2 || d[0].Date.Unix() != 1691760600 || d[1].Amount != 0.24 {
+		t.Errorf("got dividends %+v, want two in date order", d)
+	}
+	if q := s.Requests()[0].Query(); q.Get("events") != "div" || q.Get("range") != "1y" {
+		t.Errorf("got query %v", q)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := fake(t)
+	s.Handle("/v1/finance/search", fakeapi.OK("search.json"))
+
+	results, err := Search("apple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Name() != "Apple Inc." || results[1].Name() != "APPLE CDR (CAD HEDGED)" {
+		t.Errorf("got %+v", results)
+	}
+	if q := s.Requests()[0].Query().Get("q"); q != "apple" {
+		t.Errorf("searched for %q", q)
+	}
+}
+
+func symbols(stocks []Stock) []string {
+	var out []string
+	for _, s := range stocks {
+		out = append(out, s.Symbol)
+	}
+	return out
+}
+
+func TestMalformedStatus(t *testing.T) {
+	s := fake(t)
+	s.Handle(quotePath, fakeapi.Response{Body: `{"quoteResponse":{"result":[{"symbol":"AA`})
+
+	_, err := FetchQuote([]string{"AAPL"})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an *Error", err)
+	}
+	if apiErr.StatusCode != http.StatusOK || !strings.Contains(apiErr.Snippet, `"AA`) {
+		t.Errorf("got status %d and snippet %q, want 200 and the body", apiErr.StatusCode, apiErr.Snippet)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	for _, fixture := range []string{"quote_error_object.json", "quote_error_string.json"} {
+		s := fake(t)
+		s.Handle(quotePath, fakeapi.OK(fixture))
+
+		_, err := FetchQuote([]string{"AAPL"})
+		var apiErr *Error
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("%s: got %v, want an *Error", fixture, err)
+		}
+		if apiErr.StatusCode != http.StatusOK || apiErr.Snippet == "" {
+			t.Errorf("%s: got status %d and snippet %q, want 200 and the message", fixture, apiErr.StatusCode, apiErr.Snippet)
+		}
+	}
+}
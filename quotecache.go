@@ -0,0 +1,116 @@
+package lucrum
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+)
+
+// quoteCache is the last successful refresh, saved so the next launch can
+// show quotes before the network answers.
+type quoteCache struct {
+	Saved  time.Time
+	Stocks []yahoofinance.Stock
+}
+
+func (luc *Lucrum) quoteCachePath() string {
+	if luc.cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(luc.cacheDir, "quotes.json")
+}
+
+// loadQuotes fills the watchlist with the cached quotes of its symbols.
+func (luc *Lucrum) loadQuotes() {
+	body, err := ioutil.ReadFile(luc.quoteCachePath())
+	if err != nil {
+		return
+	}
+	var cache quoteCache
+	if json.Unmarshal(body, &cache) != nil {
+		return
+	}
+
+	cached := make(map[string]yahoofinance.Stock)
+	for _, s := range cache.Stocks {
+		cached[s.Symbol] = s
+	}
+	for i, s := range luc.stocks {
+		if c, ok := cached[s.Symbol]; ok {
+			luc.stocks[i] = c
+		}
+	}
+	luc.quotesTime = cache.Saved
+	luc.cached = true
+	luc.setStatus("Quotes from %s, refreshing", luc.age(cache.Saved))
+}
+
+// saveQuotes caches the current quotes. The cache is only a convenience,
+// so failures are ignored.
+func (luc *Lucrum) saveQuotes() {
+	if luc.quotesTime.IsZero() {
+		return
+	}
+	path := luc.quoteCachePath()
+	if path == "" {
+		return
+	}
+	var stocks []yahoofinance.Stock
+	for _, s := range luc.stocks {
+		if !isGroup(s.Symbol) {
+			stocks = append(stocks, s)
+		}
+	}
+	body, err := json.Marshal(quoteCache{Saved: luc.quotesTime, Stocks: stocks})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return
+	}
+	if os.Rename(tmp, path) != nil {
+		os.Remove(tmp)
+	}
+}
+
+// goOffline shows the cached quotes read-only until a refresh succeeds.
+func (luc *Lucrum) goOffline(err error) {
+	luc.offline = true
+	if luc.quotesTime.IsZero() {
+		luc.setStatus("Offline, no quotes cached (read-only): %s", err)
+		return
+	}
+	luc.setStatus("Offline, quotes from %s (read-only)", luc.age(luc.quotesTime))
+}
+
+// readOnly refuses changes to the watchlist while offline, since new
+// symbols can't be checked and the quotes shown are out of date.
+func (luc *Lucrum) readOnly() bool {
+	if luc.offline {
+		luc.setStatus("Offline, the watchlist is read-only")
+	}
+	return luc.offline
+}
+
+// age describes how long ago t was, e.g. "3h ago".
+func (luc *Lucrum) age(t time.Time) string {
+	d := luc.now().Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
@@ -0,0 +1,126 @@
+package lucrum
+
+import (
+	"time"
+
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+const flashDuration = time.Second
+
+// renderedRow is what was last written for a symbol, so a refresh only
+// touches cells whose text or colour changed. It's kept by symbol rather
+// than by row so adding or removing one symbol doesn't make the rows after
+// it look changed; their cells are only rewritten for having moved.
+type renderedRow struct {
+	row   int
+	cells []string
+	color tcell.Color
+}
+
+type flashCell struct {
+	symbol string
+	column int
+}
+
+// recordTicks compares fresh quotes against the previous ones so the next
+// render can flash the cells of symbols that ticked up or down.
+func (luc *Lucrum) recordTicks(previous map[string]yahoofinance.Stock, stocks []yahoofinance.Stock) {
+	for _, s := range stocks {
+		p, ok := previous[s.Symbol]
+		if !ok || p.RegularMarketPrice == 0 {
+			continue
+		}
+		if s.RegularMarketPrice > p.RegularMarketPrice {
+			luc.ticks[s.Symbol] = 1
+		} else if s.RegularMarketPrice < p.RegularMarketPrice {
+			luc.ticks[s.Symbol] = -1
+		}
+	}
+}
+
+func (luc *Lucrum) rowColor(s yahoofinance.Stock) tcell.Color {
+	if _, ok := luc.flags[s.Symbol]; ok || luc.cached {
+		return luc.theme.stale
+	} else if s.RegularMarketChange > 0 {
+		return luc.theme.up
+	} else if s.RegularMarketChange < 0 {
+		return luc.theme.down
+	}
+	return luc.theme.neutral
+}
+
+func (luc *Lucrum) rowCells(s yahoofinance.Stock) []string {
+	cells := make([]string, len(luc.columns))
+	if isGroup(s.Symbol) {
+		cells[0] = groupName(s.Symbol)
+		return cells
+	}
+	for key, col := range luc.columns {
+		cells[key] = col.value(luc, s)
+	}
+	return cells
+}
+
+func (luc *Lucrum) updateStockRows() {
+	now := luc.now()
+	luc.updateView()
+	rendered := make(map[string]renderedRow, len(luc.view))
+	for i, index := range luc.view {
+		s := luc.stocks[index]
+		row := i + 1
+		cells := luc.rowCells(s)
+		color := luc.rowColor(s)
+
+		prev, seen := luc.rendered[s.Symbol]
+		rewrite := !seen || prev.row != row || prev.color != color || len(prev.cells) != len(cells)
+
+		for key, text := range cells {
+			if !rewrite && prev.cells[key] == text {
+				continue
+			}
+			cell := generateCell(text, cview.AlignRight, color)
+			if isGroup(s.Symbol) {
+				cell.SetAlign(cview.AlignLeft).SetAttributes(tcell.AttrBold | tcell.AttrUnderline)
+			} else if tick := luc.ticks[s.Symbol]; tick != 0 && seen && key < len(prev.cells) && prev.cells[key] != text {
+				luc.flash(cell, tick)
+				luc.flashes[flashCell{s.Symbol, key}] = now.Add(flashDuration)
+			}
+			luc.stockTable.SetCell(row, key, cell)
+		}
+		rendered[s.Symbol] = renderedRow{row, cells, color}
+	}
+	luc.rendered = rendered
+
+	// Drop rows left over from symbols that are gone or hidden
+	for row := luc.stockTable.GetRowCount() - 1; row > len(luc.view); row-- {
+		luc.stockTable.RemoveRow(row)
+	}
+	luc.ticks = make(map[string]int)
+}
+
+// clearFlashes restores cells whose flash has run out to their row colour.
+func (luc *Lucrum) clearFlashes() {
+	now := luc.now()
+	for fc, until := range luc.flashes {
+		if now.Before(until) {
+			continue
+		}
+		delete(luc.flashes, fc)
+		if r, ok := luc.rendered[fc.symbol]; ok && fc.column < len(r.cells) {
+			luc.stockTable.GetCell(r.row, fc.column).SetBackgroundColor(r.color).SetAttributes(0)
+		}
+	}
+}
+
+func (luc *Lucrum) flash(cell *cview.TableCell, tick int) {
+	if luc.theme.monochrome {
+		cell.SetAttributes(tcell.AttrReverse)
+	} else if tick > 0 {
+		cell.SetBackgroundColor(luc.theme.flashUp)
+	} else {
+		cell.SetBackgroundColor(luc.theme.flashDown)
+	}
+}
@@ -0,0 +1,48 @@
+package lucrum
+
+import (
+	"time"
+
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+	"github.com/gdamore/tcell"
+)
+
+// QuoteSource is where quotes, candles and symbol searches come from.
+type QuoteSource interface {
+	FetchQuote(symbols []string) ([]yahoofinance.Stock, error)
+	FetchChart(symbol, chartRange, interval string) (yahoofinance.Chart, error)
+	Search(query string) ([]yahoofinance.SearchResult, error)
+}
+
+// yahooSource is the QuoteSource of a normal session.
+type yahooSource struct{}
+
+func (yahooSource) FetchQuote(symbols []string) ([]yahoofinance.Stock, error) {
+	return yahoofinance.FetchQuote(symbols)
+}
+
+func (yahooSource) FetchChart(symbol, chartRange, interval string) (yahoofinance.Chart, error) {
+	return yahoofinance.FetchChart(symbol, chartRange, interval)
+}
+
+func (yahooSource) Search(query string) ([]yahoofinance.SearchResult, error) {
+	return yahoofinance.Search(query)
+}
+
+// Options replace what lucrum would otherwise take from the environment,
+// so it can run headless in tests. The zero value is a normal session.
+type Options struct {
+	// Config file, "conf" in the working directory when empty
+	ConfigPath string
+	// Directory of the quote and coin list caches, lucrum's directory in
+	// the user's cache directory when empty
+	CacheDir string
+
+	// Screen to draw on instead of the terminal, e.g. a
+	// tcell.SimulationScreen. New initialises it.
+	Screen tcell.Screen
+	// Yahoo when nil
+	Quotes QuoteSource
+	// time.Now when nil
+	Now func() time.Time
+}
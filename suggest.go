@@ -0,0 +1,128 @@
+package lucrum
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gitlab.com/tslocum/cview"
+)
+
+const (
+	maxSymbolSuggestions = 8
+	maxCoinSuggestions   = 4
+)
+
+// Suggestions are inserted into the input as "SYMBOL ‹description›" so the
+// user can see what they picked; descriptions are stripped before use.
+var suggestionDescription = regexp.MustCompile(`\s*‹[^›]*›`)
+
+type suggester struct {
+	mutex   sync.Mutex
+	results map[string][]string
+	pending map[string]bool
+}
+
+func newSuggester() *suggester {
+	return &suggester{
+		results: make(map[string][]string),
+		pending: make(map[string]bool),
+	}
+}
+
+func stripSuggestions(text string) string {
+	return suggestionDescription.ReplaceAllString(text, "")
+}
+
+// autocomplete returns the suggestions for the last word of text. Lookups
+// run in the background and input.Autocomplete is called again when they
+// finish, so typing never waits on the network.
+func (luc *Lucrum) autocomplete(input *cview.InputField, text string) []string {
+	clean := stripSuggestions(text)
+	if clean == "" || strings.HasSuffix(clean, " ") {
+		return nil
+	}
+	words := strings.Split(clean, " ")
+	query := words[len(words)-1]
+	prefix := strings.Join(words[:len(words)-1], " ")
+	if prefix != "" {
+		prefix += " "
+	}
+
+	luc.suggest.mutex.Lock()
+	results, ok := luc.suggest.results[strings.ToUpper(query)]
+	pending := luc.suggest.pending[strings.ToUpper(query)]
+	if !ok && !pending {
+		luc.suggest.pending[strings.ToUpper(query)] = true
+	}
+	luc.suggest.mutex.Unlock()
+
+	if !ok {
+		if !pending {
+			go luc.lookupSuggestions(input, query)
+		}
+		return nil
+	}
+
+	entries := make([]string, len(results))
+	for i, r := range results {
+		entries[i] = prefix + r
+	}
+	return entries
+}
+
+func (luc *Lucrum) lookupSuggestions(input *cview.InputField, query string) {
+	var entries []string
+
+	results, err := luc.quotes.Search(query)
+	if err != nil {
+		// Nothing is cached so the next keystroke tries again
+		luc.suggest.mutex.Lock()
+		delete(luc.suggest.pending, strings.ToUpper(query))
+		luc.suggest.mutex.Unlock()
+		return
+	}
+	for _, r := range results {
+		if len(entries) >= maxSymbolSuggestions {
+			break
+		}
+		entries = append(entries, fmt.Sprintf("%s ‹%s · %s · %s›", r.Symbol, r.ExchDisp, r.TypeDisp, r.Name()))
+	}
+	entries = append(entries, luc.suggestCoins(query)...)
+
+	luc.suggest.mutex.Lock()
+	luc.suggest.results[strings.ToUpper(query)] = entries
+	delete(luc.suggest.pending, strings.ToUpper(query))
+	luc.suggest.mutex.Unlock()
+
+	luc.cviewApp.QueueUpdateDraw(func() {
+		words := strings.Split(stripSuggestions(input.GetText()), " ")
+		if strings.EqualFold(words[len(words)-1], query) {
+			input.Autocomplete()
+		}
+	})
+}
+
+// suggestCoins matches the query against CoinGecko's coin list. Coins are
+// suggested under Yahoo's SYMBOL-CURRENCY tickers so they can be quoted, in
+// the currency the leaderboard uses.
+func (luc *Lucrum) suggestCoins(query string) []string {
+	coins, err := luc.coins.List()
+	if err != nil {
+		return nil
+	}
+
+	var entries []string
+	currency := strings.ToUpper(luc.currency())
+	q := strings.ToLower(query)
+	for _, c := range coins {
+		if len(entries) >= maxCoinSuggestions {
+			break
+		}
+		if c.Symbol == q || strings.HasPrefix(strings.ToLower(c.Name), q) {
+			entries = append(entries, fmt.Sprintf("%s-%s ‹CoinGecko · Cryptocurrency · %s›", strings.ToUpper(c.Symbol), currency, c.Name))
+		}
+	}
+	return entries
+}
@@ -0,0 +1,46 @@
+package lucrum
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/tslocum/cview"
+)
+
+func TestSuggestions(t *testing.T) {
+	quotes := newFakeQuotes(quote("AAPL", 172.87), quote("AMZN", 178.2))
+	h := newHarness(t, `Symbols = ["AAPL"]
+Currency = "eur"`, quotes)
+	coins := `[{"id":"bitcoin","symbol":"btc","name":"Bitcoin"}]`
+	if err := ioutil.WriteFile(filepath.Join(h.dir, "cache", "coinlist.json"), []byte(coins), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := cview.NewInputField()
+	cached := func(query string) ([]string, bool) {
+		h.luc.suggest.mutex.Lock()
+		defer h.luc.suggest.mutex.Unlock()
+		results, ok := h.luc.suggest.results[query]
+		return results, ok
+	}
+
+	// A failed search isn't cached, so it's tried again
+	quotes.fail(errors.New("no network"))
+	h.luc.lookupSuggestions(input, "a")
+	if _, ok := cached("A"); ok {
+		t.Fatal("cached the suggestions of a failed search")
+	}
+	quotes.fail(nil)
+	h.luc.lookupSuggestions(input, "a")
+	if results, _ := cached("A"); len(results) != 2 {
+		t.Errorf("got suggestions %q, want AAPL and AMZN", results)
+	}
+
+	// Coins are suggested in the configured currency
+	h.luc.lookupSuggestions(input, "bit")
+	if results, _ := cached("BIT"); len(results) != 1 || !strings.HasPrefix(results[0], "BTC-EUR ") {
+		t.Errorf("got coin suggestions %q, want BTC-EUR", results)
+	}
+}
@@ -0,0 +1,161 @@
+package lucrum
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"gitlab.com/tslocum/cview"
+)
+
+// themeConfig is the [theme] section of the config. Preset picks one of the
+// built in themes and any colour set alongside it overrides the preset's.
+// Colours are tcell names such as "palegreen" or hex values like "#1f77b4".
+type themeConfig struct {
+	Preset    string `toml:",omitempty"`
+	Up        string `toml:",omitempty"`
+	Down      string `toml:",omitempty"`
+	Neutral   string `toml:",omitempty"`
+	Header    string `toml:",omitempty"`
+	Selection string `toml:",omitempty"`
+	Border    string `toml:",omitempty"`
+	Stale     string `toml:",omitempty"`
+}
+
+type theme struct {
+	up        tcell.Color
+	down      tcell.Color
+	neutral   tcell.Color
+	header    tcell.Color
+	selection tcell.Color
+	border    tcell.Color
+	stale     tcell.Color
+	flashUp   tcell.Color
+	flashDown tcell.Color
+
+	// Monochrome themes mark direction with glyphs and flash by reversing
+	// the cell instead of colouring it
+	monochrome bool
+}
+
+var themes = map[string]theme{
+	"default": {
+		up:        tcell.ColorPaleGreen,
+		down:      tcell.ColorPaleVioletRed,
+		neutral:   tcell.ColorDefault,
+		header:    tcell.ColorDefault,
+		selection: tcell.ColorWhite,
+		border:    tcell.ColorDefault,
+		stale:     tcell.ColorDarkGray,
+		flashUp:   tcell.ColorGreen,
+		flashDown: tcell.ColorRed,
+	},
+	// Blue and orange stay distinct under the common kinds of colour blindness
+	"colorblind": {
+		up:        tcell.ColorLightSkyBlue,
+		down:      tcell.ColorSandyBrown,
+		neutral:   tcell.ColorDefault,
+		header:    tcell.ColorDefault,
+		selection: tcell.ColorWhite,
+		border:    tcell.ColorDefault,
+		stale:     tcell.ColorDarkGray,
+		flashUp:   tcell.ColorDodgerBlue,
+		flashDown: tcell.ColorDarkOrange,
+	},
+	"monochrome": {
+		up:         tcell.ColorDefault,
+		down:       tcell.ColorDefault,
+		neutral:    tcell.ColorDefault,
+		header:     tcell.ColorDefault,
+		selection:  tcell.ColorDefault,
+		border:     tcell.ColorDefault,
+		stale:      tcell.ColorDefault,
+		flashUp:    tcell.ColorDefault,
+		flashDown:  tcell.ColorDefault,
+		monochrome: true,
+	},
+}
+
+// loadTheme builds the theme from the config. NO_COLOR (see no-color.org)
+// always wins over the config.
+func loadTheme(conf *themeConfig) (theme, error) {
+	if conf == nil {
+		conf = &themeConfig{}
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return themes["monochrome"], nil
+	}
+
+	t, ok := themes[conf.Preset]
+	if conf.Preset == "" {
+		t = themes["default"]
+	} else if !ok {
+		return t, fmt.Errorf("Unknown preset in [theme]: %q", conf.Preset)
+	}
+	if t.monochrome {
+		return t, nil
+	}
+	var err error
+	override := func(c *tcell.Color, key, name string) {
+		if name == "" || err != nil {
+			return
+		}
+		color, ok := parseColor(name)
+		if !ok {
+			err = fmt.Errorf("Unknown colour in [theme]: %s = %q", key, name)
+			return
+		}
+		*c = color
+	}
+	override(&t.up, "Up", conf.Up)
+	override(&t.down, "Down", conf.Down)
+	override(&t.neutral, "Neutral", conf.Neutral)
+	override(&t.header, "Header", conf.Header)
+	override(&t.selection, "Selection", conf.Selection)
+	override(&t.border, "Border", conf.Border)
+	override(&t.stale, "Stale", conf.Stale)
+	return t, err
+}
+
+// parseColor reads a tcell colour name, "default" or a hex value. Unlike
+// tcell.GetColor it tells a misspelt name from the default colour.
+func parseColor(name string) (tcell.Color, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "default" {
+		return tcell.ColorDefault, true
+	}
+	if c, ok := tcell.ColorNames[name]; ok {
+		return c, true
+	}
+	if len(name) == 7 && name[0] == '#' {
+		if v, err := strconv.ParseInt(name[1:], 16, 32); err == nil {
+			return tcell.NewHexColor(int32(v)), true
+		}
+	}
+	return tcell.ColorDefault, false
+}
+
+func (luc *Lucrum) applyTheme() {
+	cview.Styles.BorderColor = luc.theme.border
+	cview.Styles.TitleColor = luc.theme.border
+	if luc.theme.monochrome {
+		luc.stockTable.SetSelectedStyle(tcell.ColorDefault, tcell.ColorDefault, tcell.AttrReverse)
+	} else {
+		luc.stockTable.SetSelectedStyle(tcell.ColorBlack, luc.theme.selection, 0)
+	}
+}
+
+// glyph marks the direction of a change for themes without colour.
+func (luc *Lucrum) glyph(change float64) string {
+	if !luc.theme.monochrome {
+		return ""
+	}
+	if change > 0 {
+		return "▲ "
+	} else if change < 0 {
+		return "▼ "
+	}
+	return ""
+}
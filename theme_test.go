@@ -0,0 +1,60 @@
+package lucrum
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gdamore/tcell"
+)
+
+func TestLoadTheme(t *testing.T) {
+	tests := []struct {
+		conf themeConfig
+		err  string
+	}{
+		{themeConfig{}, ""},
+		{themeConfig{Preset: "colorblind", Up: "Blue", Down: "#ff8000", Border: "default"}, ""},
+		{themeConfig{Preset: "solarised"}, `Unknown preset in [theme]: "solarised"`},
+		{themeConfig{Up: "gren"}, `Unknown colour in [theme]: Up = "gren"`},
+		{themeConfig{Stale: "#12345"}, `Unknown colour in [theme]: Stale = "#12345"`},
+	}
+	for _, test := range tests {
+		_, err := loadTheme(&test.conf)
+		if got := ""; err != nil {
+			got = err.Error()
+			if got != test.err {
+				t.Errorf("%+v: got error %q, want %q", test.conf, got, test.err)
+			}
+		} else if test.err != "" {
+			t.Errorf("%+v: got no error, want %q", test.conf, test.err)
+		}
+	}
+
+	th, _ := loadTheme(&themeConfig{Preset: "colorblind", Up: "Blue", Down: "#ff8000"})
+	if th.up != tcell.ColorBlue || th.down != tcell.NewHexColor(0xff8000) || th.flashUp != themes["colorblind"].flashUp {
+		t.Errorf("overrides not applied: %+v", th)
+	}
+}
+
+func TestThemeSection(t *testing.T) {
+	// Configs written before the section was lowercase still load
+	for _, section := range []string{"theme", "Theme"} {
+		var conf config
+		if _, err := toml.Decode("["+section+"]\nPreset = \"colorblind\"\n", &conf); err != nil {
+			t.Fatal(err)
+		}
+		if conf.Theme == nil || conf.Theme.Preset != "colorblind" {
+			t.Errorf("[%s] not read: %+v", section, conf.Theme)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := toml.NewEncoder(&b).Encode(config{Theme: &themeConfig{Preset: "monochrome"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "[theme]") {
+		t.Errorf("saved as:\n%s", b.String())
+	}
+}
@@ -0,0 +1,112 @@
+package lucrum
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anorb/lucrum/pkg/fetch"
+	"github.com/anorb/lucrum/pkg/yahoofinance"
+)
+
+// Quotes whose last trade is older than this are flagged as possibly
+// delisted or halted.
+const staleQuoteAge = 30 * 24 * time.Hour
+
+// validateSymbols quotes the given symbols and returns the ones the
+// provider knows about along with a reason for each one it doesn't.
+func (luc *Lucrum) validateSymbols(symbols []string) ([]yahoofinance.Stock, map[string]string, error) {
+	unknown := make(map[string]string)
+	stocks, err := luc.quotes.FetchQuote(symbols)
+	var batch *fetch.BatchError
+	if errors.As(err, &batch) && len(stocks) > 0 {
+		for _, sym := range batch.Failed() {
+			unknown[sym] = "could not be checked, try again"
+		}
+	} else if err != nil && !errors.Is(err, yahoofinance.ErrNotFound) {
+		return nil, unknown, err
+	}
+
+	found := make(map[string]bool)
+	for _, s := range stocks {
+		found[s.Symbol] = true
+	}
+	for _, sym := range symbols {
+		if _, ok := unknown[sym]; !ok && !found[sym] {
+			unknown[sym] = "unknown symbol" + luc.suggestReplacement(sym, sym)
+		}
+	}
+	return stocks, unknown, nil
+}
+
+// checkResolved flags symbols that did not come back from the provider or
+// have not traded in a long time. Flags are kept until the symbol resolves
+// again so the replacement search only runs once per symbol, in the
+// background. Symbols whose request failed are left as they were.
+func (luc *Lucrum) checkResolved(previous map[string]yahoofinance.Stock, fetched, failed map[string]bool) {
+	for _, s := range luc.stocks {
+		if isGroup(s.Symbol) || failed[s.Symbol] {
+			continue
+		}
+		if fetched[s.Symbol] {
+			if s.RegularMarketTime > 0 && luc.now().Sub(time.Unix(int64(s.RegularMarketTime), 0)) > staleQuoteAge {
+				luc.flags[s.Symbol] = "no trades since " + time.Unix(int64(s.RegularMarketTime), 0).Format("2006-01-02")
+			} else {
+				delete(luc.flags, s.Symbol)
+			}
+			continue
+		}
+		if _, ok := luc.flags[s.Symbol]; ok {
+			continue
+		}
+		// A company name finds renamed tickers better than the old ticker
+		query := s.Symbol
+		if p, ok := previous[s.Symbol]; ok && p.ShortName != "" {
+			query = p.ShortName
+		}
+		reason := "no longer returned by Yahoo"
+		luc.flags[s.Symbol] = reason
+		go luc.lookupReplacement(s.Symbol, query, reason)
+	}
+}
+
+// lookupReplacement searches for a replacement of a flagged symbol off the
+// UI and adds it to the flag, unless the flag changed meanwhile.
+func (luc *Lucrum) lookupReplacement(symbol, query, reason string) {
+	suggestion := luc.suggestReplacement(symbol, query)
+	if suggestion == "" {
+		return
+	}
+	luc.cviewApp.QueueUpdateDraw(func() {
+		if luc.flags[symbol] == reason {
+			luc.flags[symbol] = reason + suggestion
+		}
+	})
+}
+
+func (luc *Lucrum) suggestReplacement(symbol, query string) string {
+	results, err := luc.quotes.Search(query)
+	if err != nil {
+		return ""
+	}
+	for _, r := range results {
+		if r.Symbol != symbol {
+			return fmt.Sprintf(", did you mean %s (%s)?", r.Symbol, r.Name())
+		}
+	}
+	return ""
+}
+
+func (luc *Lucrum) flagSummary() string {
+	if len(luc.flags) == 0 {
+		return ""
+	}
+	var symbols []string
+	for sym := range luc.flags {
+		symbols = append(symbols, sym)
+	}
+	sort.Strings(symbols)
+	return fmt.Sprintf("Not resolving: %s (select a row for details)", strings.Join(symbols, ", "))
+}
@@ -0,0 +1,80 @@
+package lucrum
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Names accepted by :list and the Yahoo quote types they show.
+var quoteTypes = map[string]string{
+	"equity":   "EQUITY",
+	"etf":      "ETF",
+	"fund":     "MUTUALFUND",
+	"index":    "INDEX",
+	"crypto":   "CRYPTOCURRENCY",
+	"currency": "CURRENCY",
+	"future":   "FUTURE",
+}
+
+// updateView works out which rows of the watchlist are shown and in what
+// order. luc.view holds indexes into luc.stocks, one per table row.
+func (luc *Lucrum) updateView() {
+	luc.view = luc.view[:0]
+	for i, s := range luc.stocks {
+		if luc.listType != "" && (isGroup(s.Symbol) || s.QuoteType != luc.listType) {
+			continue
+		}
+		// Groups only make sense in the manual order
+		if (luc.sortColumn != "" || luc.filter != nil) && isGroup(s.Symbol) {
+			continue
+		}
+		if !isGroup(s.Symbol) && !luc.matchesFilter(s) {
+			continue
+		}
+		luc.view = append(luc.view, i)
+	}
+
+	col, ok := luc.findColumn(luc.sortColumn)
+	if !ok {
+		return
+	}
+	sort.SliceStable(luc.view, func(i, j int) bool {
+		a, b := luc.stocks[luc.view[i]], luc.stocks[luc.view[j]]
+		var less bool
+		if col.number != nil {
+			x, y := col.number(luc, a), col.number(luc, b)
+			// Missing values always go last
+			if math.IsNaN(x) || math.IsNaN(y) {
+				return !math.IsNaN(x)
+			}
+			less = x < y
+			if luc.sortDesc {
+				less = x > y
+			}
+		} else {
+			x, y := strings.ToLower(col.value(luc, a)), strings.ToLower(col.value(luc, b))
+			less = x < y
+			if luc.sortDesc {
+				less = x > y
+			}
+		}
+		return less
+	})
+}
+
+// reordered reports whether the table differs from the manual order, in
+// which case rows can't be moved by hand.
+func (luc *Lucrum) reordered() bool {
+	return luc.sortColumn != "" || luc.listType != "" || luc.filter != nil
+}
+
+// rowOf returns the table row showing luc.stocks[index], or -1.
+func (luc *Lucrum) rowOf(index int) int {
+	for row, i := range luc.view {
+		if i == index {
+			return row + 1
+		}
+	}
+	return -1
+}